@@ -0,0 +1,160 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"math/big"
+	"sort"
+)
+
+// A PrefixConflict represents a pair of conflicting address prefixes
+// in the network map.
+type PrefixConflict struct {
+	PID      string   // provider-defined identifier of Prefix
+	Prefix   Endpoint // conflicting prefix
+	OtherPID string   // provider-defined identifier of Other
+	Other    Endpoint // prefix equal to, covering or covered by Prefix
+
+	// Winner is the provider-defined identifier selected by
+	// longest-prefix match for addresses within both prefixes. It is
+	// empty when the match is ambiguous.
+	Winner string
+}
+
+// An AddrCoverage represents a coverage of address space.
+type AddrCoverage struct {
+	Network string     // address type; "ipv4" or "ipv6"
+	Covered *big.Int   // number of addresses that belong to any PID
+	Ratio   float64    // ratio of covered addresses to address space
+	Gaps    []Endpoint // prefixes that belong to no PID
+}
+
+// A NetworkMapAnalysis represents a result of network map analysis.
+type NetworkMapAnalysis struct {
+	Duplicates []PrefixConflict // same prefixes appearing more than once
+	Overlaps   []PrefixConflict // prefixes covered by prefixes of other PIDs
+	Shadows    []PrefixConflict // IPv4-mapped IPv6 prefixes overlapping IPv4 prefixes of other PIDs
+	IPv4       AddrCoverage
+	IPv6       AddrCoverage
+}
+
+type prefixEntry struct {
+	p   ipPrefix
+	pid string
+	ep  Endpoint
+}
+
+type byPrefix []prefixEntry
+
+func (s byPrefix) Len() int      { return len(s) }
+func (s byPrefix) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPrefix) Less(i, j int) bool {
+	if n := s[i].p.compare(s[j].p); n != 0 {
+		return n < 0
+	}
+	return s[i].pid < s[j].pid
+}
+
+func (nm *NetworkMap) prefixEntries(typ string) []prefixEntry {
+	var pes []prefixEntry
+	for pid, eag := range nm.Map {
+		for _, ep := range eag[typ] {
+			if p, ok := toIPPrefix(ep); ok && ep.Network() == typ {
+				pes = append(pes, prefixEntry{p: p, pid: pid, ep: ep})
+			}
+		}
+	}
+	sort.Sort(byPrefix(pes))
+	return pes
+}
+
+// Analyze analyzes the IP address prefixes in the network map nm. It
+// reports duplicate prefixes, prefixes overlapping across PIDs and
+// IPv4-mapped IPv6 prefixes shadowing IPv4 prefixes, and summarizes
+// the coverage of IPv4 and IPv6 address space.
+func (nm *NetworkMap) Analyze() *NetworkMapAnalysis {
+	var a NetworkMapAnalysis
+	v4, v6 := nm.prefixEntries("ipv4"), nm.prefixEntries("ipv6")
+	for _, pes := range [][]prefixEntry{v4, v6} {
+		dups, ovls := conflicts(pes)
+		a.Duplicates = append(a.Duplicates, dups...)
+		a.Overlaps = append(a.Overlaps, ovls...)
+	}
+	for _, pe := range v6 {
+		p, ok := pe.p.unmap()
+		if !ok {
+			continue
+		}
+		for _, v4pe := range v4 {
+			if v4pe.pid == pe.pid || !p.overlaps(v4pe.p) {
+				continue
+			}
+			a.Shadows = append(a.Shadows, PrefixConflict{PID: pe.pid, Prefix: pe.ep, OtherPID: v4pe.pid, Other: v4pe.ep})
+		}
+	}
+	a.IPv4 = coverage("ipv4", 32, v4)
+	a.IPv6 = coverage("ipv6", 128, v6)
+	return &a
+}
+
+// conflicts returns duplicates and cross-PID overlaps in the sorted
+// prefix entries pes. Each overlap refers to the most specific prefix
+// of another PID covering the prefix.
+func conflicts(pes []prefixEntry) (dups, ovls []PrefixConflict) {
+	var stack []prefixEntry
+	for i, pe := range pes {
+		if i > 0 && pes[i-1].p.equal(pe.p) {
+			prev := pes[i-1]
+			pc := PrefixConflict{PID: pe.pid, Prefix: pe.ep, OtherPID: prev.pid, Other: prev.ep}
+			if prev.pid == pe.pid {
+				pc.Winner = pe.pid
+			}
+			dups = append(dups, pc)
+			continue
+		}
+		for len(stack) > 0 && !stack[len(stack)-1].p.contains(pe.p) {
+			stack = stack[:len(stack)-1]
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].pid != pe.pid {
+				ovls = append(ovls, PrefixConflict{PID: pe.pid, Prefix: pe.ep, OtherPID: stack[j].pid, Other: stack[j].ep, Winner: pe.pid})
+				break
+			}
+		}
+		stack = append(stack, pe)
+	}
+	return
+}
+
+func coverage(typ string, bits int, pes []prefixEntry) AddrCoverage {
+	cov := AddrCoverage{Network: typ, Covered: new(big.Int)}
+	one := big.NewInt(1)
+	next := new(big.Int)
+	total := new(big.Int).Lsh(one, uint(bits))
+	for _, pe := range pes {
+		first, last := pe.p.first(), pe.p.last()
+		if last.Cmp(next) < 0 {
+			continue
+		}
+		if first.Cmp(next) > 0 {
+			gap := new(big.Int).Sub(first, one)
+			for _, p := range rangeToIPPrefixes(next, gap, bits) {
+				cov.Gaps = append(cov.Gaps, p.endpoint())
+			}
+		} else {
+			first = next
+		}
+		n := new(big.Int).Sub(last, first)
+		cov.Covered.Add(cov.Covered, n.Add(n, one))
+		next = new(big.Int).Add(last, one)
+	}
+	if next.Cmp(total) < 0 {
+		for _, p := range rangeToIPPrefixes(next, new(big.Int).Sub(total, one), bits) {
+			cov.Gaps = append(cov.Gaps, p.endpoint())
+		}
+	}
+	cov.Ratio, _ = new(big.Rat).SetFrac(cov.Covered, total).Float64()
+	return cov
+}
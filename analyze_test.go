@@ -0,0 +1,81 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import "testing"
+
+func TestNetworkMapAnalyzeTestdata(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	decodeTestdata(t, "testdata/networkmap.js", nm)
+	a := nm.Analyze()
+	if len(a.Duplicates) != 1 || a.Duplicates[0].Winner != "" {
+		t.Fatalf("got %v; expected an ambiguous duplicate", a.Duplicates)
+	}
+	if len(a.Overlaps) != 2 {
+		t.Fatalf("got %v; expected %v", len(a.Overlaps), 2)
+	}
+	for _, pc := range a.Overlaps {
+		if pc.OtherPID != "pid3" || pc.Winner != pc.PID {
+			t.Fatalf("got %+v; expected overlap with %v", pc, "pid3")
+		}
+	}
+	for _, cov := range []AddrCoverage{a.IPv4, a.IPv6} {
+		if cov.Ratio != 1 || len(cov.Gaps) != 0 {
+			t.Fatalf("%v: got %v, %v; expected full coverage", cov.Network, cov.Ratio, cov.Gaps)
+		}
+	}
+}
+
+var networkMapAnalyzeTests = []struct {
+	pid  string
+	net  string
+	addr string
+}{
+	{"pid1", "ipv4", "10.0.0.0/8"},
+	{"pid2", "ipv4", "10.1.0.0/16"},
+	{"pid2", "ipv4", "10.1.0.0/16"},
+	{"pid3", "ipv6", "::ffff:10.1.2.0/120"},
+}
+
+func TestNetworkMapAnalyze(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	for _, tt := range networkMapAnalyzeTests {
+		ep, err := ParseEndpoint(tt.net, tt.addr)
+		if err != nil {
+			t.Fatalf("ParseEndpoint failed: %v", err)
+		}
+		eag, ok := nm.Map[tt.pid]
+		if !ok {
+			eag = make(EndpointAddrGroup)
+			nm.Map[tt.pid] = eag
+		}
+		eag[tt.net] = append(eag[tt.net], ep)
+	}
+	a := nm.Analyze()
+	if len(a.Duplicates) != 1 || a.Duplicates[0].Winner != "pid2" {
+		t.Fatalf("got %v; expected a duplicate in %v", a.Duplicates, "pid2")
+	}
+	if len(a.Overlaps) != 1 || a.Overlaps[0].PID != "pid2" || a.Overlaps[0].OtherPID != "pid1" {
+		t.Fatalf("got %v; expected an overlap between %v and %v", a.Overlaps, "pid2", "pid1")
+	}
+	if len(a.Shadows) != 3 {
+		t.Fatalf("got %v; expected %v", len(a.Shadows), 3)
+	}
+	if a.IPv4.Covered.Int64() != 1<<24 {
+		t.Fatalf("got %v; expected %v", a.IPv4.Covered, 1<<24)
+	}
+	gaps := []string{"0.0.0.0/5", "8.0.0.0/7", "11.0.0.0/8", "12.0.0.0/6", "16.0.0.0/4", "32.0.0.0/3", "64.0.0.0/2", "128.0.0.0/1"}
+	if len(a.IPv4.Gaps) != len(gaps) {
+		t.Fatalf("got %v; expected %v", a.IPv4.Gaps, gaps)
+	}
+	for i := range gaps {
+		if a.IPv4.Gaps[i].String() != gaps[i] {
+			t.Fatalf("got %v; expected %v", a.IPv4.Gaps[i], gaps[i])
+		}
+	}
+	if a.IPv6.Covered.Int64() != 256 {
+		t.Fatalf("got %v; expected %v", a.IPv6.Covered, 256)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package client implements an HTTP client for the Application-Layer
+// Traffic Optimization (ALTO) protocol.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+// A Client represents an ALTO client. It caches information resources
+// retrieved with GET and revalidates them with conditional requests.
+type Client struct {
+	HTTPClient *http.Client // nil means http.DefaultClient
+
+	mu    sync.Mutex
+	cache map[string]*cacheEntry // keyed by uri
+}
+
+type cacheEntry struct {
+	mediaType    string
+	etag         string
+	lastModified string
+	expires      time.Time
+	body         []byte
+}
+
+// New returns a new client.
+func New() *Client {
+	return &Client{cache: make(map[string]*cacheEntry)}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) cached(uri, mediaType string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ce, ok := c.cache[uri]; ok && ce.mediaType == mediaType {
+		return ce
+	}
+	return nil
+}
+
+func (c *Client) store(uri string, ce *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cache == nil {
+		c.cache = make(map[string]*cacheEntry)
+	}
+	c.cache[uri] = ce
+}
+
+// Get retrieves the information resource identified by uri with media
+// type mediaType and returns its JSON encoding. A cached resource is
+// returned without any request while it is fresh, and revalidated
+// with a conditional request otherwise.
+func (c *Client) Get(ctx context.Context, uri, mediaType string) ([]byte, error) {
+	ce := c.cached(uri, mediaType)
+	if ce != nil && time.Now().Before(ce.expires) {
+		return ce.body, nil
+	}
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", mediaType+","+alto.MediaTypeError)
+	if ce != nil {
+		if ce.etag != "" {
+			req.Header.Set("If-None-Match", ce.etag)
+		}
+		if ce.lastModified != "" {
+			req.Header.Set("If-Modified-Since", ce.lastModified)
+		}
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ce != nil:
+		nce := *ce
+		nce.expires = expires(resp.Header)
+		c.store(uri, &nce)
+		return ce.body, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, responseError(resp)
+	}
+	if ct := resp.Header.Get("Content-Type"); !alto.MatchMediaType(ct, mediaType) {
+		return nil, fmt.Errorf("unexpected media type %q", ct)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" {
+		c.store(uri, &cacheEntry{
+			mediaType:    mediaType,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			expires:      expires(resp.Header),
+			body:         body,
+		})
+	}
+	return body, nil
+}
+
+// expires returns the expiration time of the response with header h.
+func expires(h http.Header) time.Time {
+	now := time.Now()
+	for _, s := range strings.Split(h.Get("Cache-Control"), ",") {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "no-cache" || s == "no-store":
+			return now
+		case strings.HasPrefix(s, "max-age="):
+			if n, err := strconv.Atoi(s[len("max-age="):]); err == nil {
+				return now.Add(time.Duration(n) * time.Second)
+			}
+		}
+	}
+	return now
+}
+
+func responseError(resp *http.Response) error {
+	b, _ := ioutil.ReadAll(resp.Body)
+	if alto.MatchMediaType(resp.Header.Get("Content-Type"), alto.MediaTypeError) {
+		var e alto.Error
+		if err := json.Unmarshal(b, &e); err == nil && e.Code != "" {
+			return fmt.Errorf("%s: %w", resp.Status, &e)
+		}
+	}
+	return fmt.Errorf("%s", resp.Status)
+}
+
+func (c *Client) get(ctx context.Context, uri, mediaType string, v interface{}) error {
+	b, err := c.Get(ctx, uri, mediaType)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// Directory retrieves the information resource directory identified
+// by uri.
+func (c *Client) Directory(ctx context.Context, uri string) (*alto.Directory, error) {
+	var dir alto.Directory
+	if err := c.get(ctx, uri, alto.MediaTypeDirectory, &dir); err != nil {
+		return nil, err
+	}
+	return &dir, nil
+}
+
+// NetworkMap retrieves the network map identified by uri.
+func (c *Client) NetworkMap(ctx context.Context, uri string) (*alto.NetworkMap, error) {
+	r := alto.NewResource("networkmap")
+	if err := c.get(ctx, uri, alto.MediaTypeNetworkMap, r); err != nil {
+		return nil, err
+	}
+	return r.Data.(*alto.NetworkMap), nil
+}
+
+// CostMap retrieves the cost map identified by uri.
+func (c *Client) CostMap(ctx context.Context, uri string) (*alto.CostMap, error) {
+	r := alto.NewResource("costmap")
+	if err := c.get(ctx, uri, alto.MediaTypeCostMap, r); err != nil {
+		return nil, err
+	}
+	return r.Data.(*alto.CostMap), nil
+}
+
+// FilteredCostMap queries the filtered cost map identified by uri
+// with input parameters params.
+func (c *Client) FilteredCostMap(ctx context.Context, uri string, params *alto.ReqFilteredCostMap) (*alto.CostMap, error) {
+	r := alto.NewResource("costmap")
+	if err := c.post(ctx, uri, alto.MediaTypeCostMap, alto.MediaTypeCostMapFilter, params, r); err != nil {
+		return nil, err
+	}
+	return r.Data.(*alto.CostMap), nil
+}
+
+// EndpointProperty queries the endpoint property service identified
+// by uri with input parameters params.
+func (c *Client) EndpointProperty(ctx context.Context, uri string, params *alto.ReqEndpointProp) (*alto.EndpointProperty, error) {
+	r := alto.NewResource("endpointprop")
+	if err := c.post(ctx, uri, alto.MediaTypeEndpointProp, alto.MediaTypeEndpointPropParams, params, r); err != nil {
+		return nil, err
+	}
+	return r.Data.(*alto.EndpointProperty), nil
+}
+
+// EndpointCost queries the endpoint cost service identified by uri
+// with input parameters params.
+func (c *Client) EndpointCost(ctx context.Context, uri string, params *alto.ReqEndpointCostMap) (*alto.EndpointCostMap, error) {
+	r := alto.NewResource("endpointcost")
+	if err := c.post(ctx, uri, alto.MediaTypeEndpointCost, alto.MediaTypeEndpointCostParams, params, r); err != nil {
+		return nil, err
+	}
+	return r.Data.(*alto.EndpointCostMap), nil
+}
+
+func (c *Client) post(ctx context.Context, uri, mediaType, accepts string, params, v interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", mediaType+","+alto.MediaTypeError)
+	req.Header.Set("Content-Type", accepts)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	if ct := resp.Header.Get("Content-Type"); !alto.MatchMediaType(ct, mediaType) {
+		return fmt.Errorf("unexpected media type %q", ct)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,97 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+func TestClientCache(t *testing.T) {
+	f, err := os.Open("../testdata/resource-networkmap.js")
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	r := alto.NewResource("networkmap")
+	if err := json.NewDecoder(f).Decode(r); err != nil {
+		t.Fatalf("json.Decoder.Decode failed: %v", err)
+	}
+	modTime := time.Now()
+	h := &alto.Handler{
+		MediaType: alto.MediaTypeNetworkMap,
+		Resource:  func() (interface{}, time.Time) { return r, modTime },
+	}
+	var full, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		switch rw.Code {
+		case http.StatusOK:
+			full++
+		case http.StatusNotModified:
+			notModified++
+		}
+		for k, v := range rw.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rw.Code)
+		w.Write(rw.Body.Bytes())
+	}))
+	defer ts.Close()
+
+	c := New()
+	for i := 0; i < 3; i++ {
+		nm, err := c.NetworkMap(context.Background(), ts.URL)
+		if err != nil {
+			t.Fatalf("Client.NetworkMap failed: %v", err)
+		}
+		if nm.VersionTag != "1266506139" {
+			t.Fatalf("got %v; expected %v", nm.VersionTag, "1266506139")
+		}
+	}
+	if full != 1 || notModified != 2 {
+		t.Fatalf("got %v full and %v conditional responses; expected 1 and 2", full, notModified)
+	}
+
+	h.MaxAge = time.Hour
+	r.Data.(*alto.NetworkMap).VersionTag = "1266506140"
+	if _, err := c.NetworkMap(context.Background(), ts.URL); err != nil {
+		t.Fatalf("Client.NetworkMap failed: %v", err)
+	}
+	if _, err := c.NetworkMap(context.Background(), ts.URL); err != nil {
+		t.Fatalf("Client.NetworkMap failed: %v", err)
+	}
+	if full != 2 || notModified != 2 {
+		t.Fatalf("got %v full and %v conditional responses; expected 2 and 2", full, notModified)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	h := &alto.Handler{
+		MediaType: alto.MediaTypeCostMap,
+		Resource:  func() (interface{}, time.Time) { return alto.NewResource("costmap"), time.Time{} },
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	c := New()
+	_, err := c.NetworkMap(context.Background(), ts.URL)
+	var e *alto.Error
+	if !errors.As(err, &e) || e.Code != alto.ErrSyntax {
+		t.Fatalf("got %v; expected %v", err, alto.ErrSyntax)
+	}
+	if _, err := c.CostMap(context.Background(), ts.URL); err != nil {
+		t.Fatalf("Client.CostMap failed: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/mikioh/alto"
+)
+
+// A CatalogEntry represents an information resource found by walking
+// information resource directories.
+type CatalogEntry struct {
+	alto.DirectoryResource // information resource with absolute URI
+
+	Directory string                   // absolute URI of directory listing the resource
+	Depth     int                      // depth of directory; 0 for root directory
+	CostTypes map[string]alto.CostType // cost types named in capabilities
+}
+
+// A Catalog represents a flattened list of information resources.
+type Catalog []CatalogEntry
+
+// CostMaps returns the cost maps providing the cost type ct.
+func (cat Catalog) CostMaps(ct alto.CostType) []CatalogEntry {
+	var es []CatalogEntry
+	for _, e := range cat {
+		if e.MediaType != alto.MediaTypeCostMap {
+			continue
+		}
+		for _, v := range e.CostTypes {
+			if v.CostMetric == ct.CostMetric && v.CostMode == ct.CostMode {
+				es = append(es, e)
+				break
+			}
+		}
+	}
+	return es
+}
+
+// WalkDirectory retrieves the information resource directory
+// identified by uri, and follows nested directories up to maxDepth
+// levels. Relative URIs are resolved against the URI of the directory
+// listing them, and each directory is retrieved at most once. It
+// returns a catalog of all information resources found, including
+// nested directories.
+func (c *Client) WalkDirectory(ctx context.Context, uri string, maxDepth int) (Catalog, error) {
+	base, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	w := walker{c: c, visited: make(map[string]bool), maxDepth: maxDepth}
+	if err := w.walk(ctx, base, 0); err != nil {
+		return nil, err
+	}
+	return w.cat, nil
+}
+
+type walker struct {
+	c        *Client
+	visited  map[string]bool
+	maxDepth int
+	cat      Catalog
+}
+
+func (w *walker) walk(ctx context.Context, base *url.URL, depth int) error {
+	w.visited[base.String()] = true
+	dir, err := w.c.Directory(ctx, base.String())
+	if err != nil {
+		return fmt.Errorf("%s: %w", base, err)
+	}
+	cts := dir.Meta.CostTypes()
+	var nested []*url.URL
+	for _, dr := range dir.Resources {
+		ref, err := url.Parse(dr.URI)
+		if err != nil {
+			return fmt.Errorf("%s: %w", base, err)
+		}
+		u := base.ResolveReference(ref)
+		dr.URI = u.String()
+		e := CatalogEntry{DirectoryResource: dr, Directory: base.String(), Depth: depth}
+		for _, name := range dr.CostTypeNames() {
+			if ct, ok := cts[name]; ok {
+				if e.CostTypes == nil {
+					e.CostTypes = make(map[string]alto.CostType)
+				}
+				e.CostTypes[name] = ct
+			}
+		}
+		w.cat = append(w.cat, e)
+		if dr.MediaType == alto.MediaTypeDirectory && depth < w.maxDepth && !w.visited[u.String()] {
+			w.visited[u.String()] = true
+			nested = append(nested, u)
+		}
+	}
+	for _, u := range nested {
+		if err := w.walk(ctx, u, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
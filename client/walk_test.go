@@ -0,0 +1,76 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+var walkDirectories = map[string]string{
+	"/directory": `{
+		"meta": {"cost-types": {"num-routing": {"cost-mode": "numerical", "cost-metric": "routingcost"}}},
+		"resources": [
+			{"uri": "/networkmap", "media-type": "application/alto-networkmap+json"},
+			{"uri": "costmap/num/routingcost", "media-type": "application/alto-costmap+json", "capabilities": {"cost-type-names": ["num-routing"]}},
+			{"uri": "/federated/directory", "media-type": "application/alto-directory+json"}
+		]
+	}`,
+	"/federated/directory": `{
+		"meta": {"cost-types": {"ord-hop": {"cost-mode": "ordinal", "cost-metric": "hopcount"}}},
+		"resources": [
+			{"uri": "costmap/ord/hopcount", "media-type": "application/alto-costmap+json", "capabilities": {"cost-type-names": ["ord-hop"]}},
+			{"uri": "../directory", "media-type": "application/alto-directory+json"},
+			{"uri": "deeper/directory", "media-type": "application/alto-directory+json"}
+		]
+	}`,
+	"/federated/deeper/directory": `{
+		"meta": {},
+		"resources": [
+			{"uri": "/never", "media-type": "application/alto-networkmap+json"}
+		]
+	}`,
+}
+
+func TestWalkDirectory(t *testing.T) {
+	visits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s, ok := walkDirectories[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		visits[req.URL.Path]++
+		w.Header().Set("Content-Type", alto.MediaTypeDirectory)
+		w.Write([]byte(s))
+	}))
+	defer ts.Close()
+
+	cat, err := New().WalkDirectory(context.Background(), ts.URL+"/directory", 1)
+	if err != nil {
+		t.Fatalf("Client.WalkDirectory failed: %v", err)
+	}
+	if len(cat) != 6 {
+		t.Fatalf("got %v; expected %v entries", cat, 6)
+	}
+	if visits["/directory"] != 1 || visits["/federated/directory"] != 1 || visits["/federated/deeper/directory"] != 0 {
+		t.Fatalf("got %v; expected each directory within depth visited once", visits)
+	}
+	cms := cat.CostMaps(alto.CostType{CostMetric: "hopcount", CostMode: alto.CostModeOrdinal})
+	if len(cms) != 1 {
+		t.Fatalf("got %v; expected %v", cms, 1)
+	}
+	if cms[0].URI != ts.URL+"/federated/costmap/ord/hopcount" || cms[0].Depth != 1 || cms[0].Directory != ts.URL+"/federated/directory" {
+		t.Fatalf("got %+v", cms[0])
+	}
+	cms = cat.CostMaps(alto.CostType{CostMetric: "routingcost", CostMode: alto.CostModeNumerical})
+	if len(cms) != 1 || cms[0].URI != ts.URL+"/costmap/num/routingcost" {
+		t.Fatalf("got %v; expected %v", cms, ts.URL+"/costmap/num/routingcost")
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/rank"
+)
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	return fs
+}
+
+func cmdIRD(ctx context.Context, s *session, args []string) error {
+	cat, err := s.catalog(ctx)
+	if err != nil {
+		return err
+	}
+	if s.json {
+		dir, err := s.c.Directory(ctx, s.ird)
+		if err != nil {
+			return err
+		}
+		return s.writeJSON(dir)
+	}
+	return s.writeTable([]string{"ID", "URI", "MEDIA TYPE", "ACCEPTS", "COST TYPES"}, func(row func(...interface{})) {
+		for _, e := range cat {
+			names := e.CostTypeNames()
+			sort.Strings(names)
+			row(e.ID, e.URI, e.MediaType, e.Accepts, strings.Join(names, ","))
+		}
+	})
+}
+
+func cmdNetworkMap(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("netmap")
+	id := fs.String("id", "", "resource id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	nm, err := s.networkMap(ctx, *id)
+	if err != nil {
+		return err
+	}
+	if s.json {
+		return s.writeJSON(nm)
+	}
+	return s.writeTable([]string{"PID", "TYPE", "ENDPOINTS"}, func(row func(...interface{})) {
+		for _, pid := range sortedKeys(nm.Map) {
+			eag := nm.Map[pid]
+			var typs []string
+			for typ := range eag {
+				typs = append(typs, typ)
+			}
+			sort.Strings(typs)
+			for _, typ := range typs {
+				var ss []string
+				for _, ep := range eag[typ] {
+					ss = append(ss, ep.String())
+				}
+				row(pid, typ, strings.Join(ss, " "))
+			}
+		}
+	})
+}
+
+func (s *session) networkMap(ctx context.Context, id string) (*alto.NetworkMap, error) {
+	e, err := s.resource(ctx, id, alto.MediaTypeNetworkMap, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return s.c.NetworkMap(ctx, e.URI)
+}
+
+func cmdCostMap(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("costmap")
+	id := fs.String("id", "", "resource id")
+	name := fs.String("type", "", "cost type name")
+	srcs := fs.String("srcs", "", "comma-separated source PIDs")
+	dsts := fs.String("dsts", "", "comma-separated destination PIDs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("no cost type; use -type")
+	}
+	var cm *alto.CostMap
+	if *srcs == "" && *dsts == "" {
+		e, err := s.resource(ctx, *id, alto.MediaTypeCostMap, "", *name)
+		if err != nil {
+			return err
+		}
+		if cm, err = s.c.CostMap(ctx, e.URI); err != nil {
+			return err
+		}
+	} else {
+		e, err := s.resource(ctx, *id, alto.MediaTypeCostMap, alto.MediaTypeCostMapFilter, *name)
+		if err != nil {
+			return err
+		}
+		var params alto.ReqFilteredCostMap
+		params.CostType = e.CostTypes[*name]
+		params.PIDs.Srcs = splitList(*srcs)
+		params.PIDs.Dsts = splitList(*dsts)
+		if cm, err = s.c.FilteredCostMap(ctx, e.URI, &params); err != nil {
+			return err
+		}
+	}
+	if s.json {
+		return s.writeJSON(cm)
+	}
+	dstSet := make(map[string]bool)
+	for _, dcs := range cm.Map {
+		for dst := range dcs {
+			dstSet[dst] = true
+		}
+	}
+	cols := sortedKeys(dstSet)
+	return s.writeTable(append([]string{"SRC \\ DST"}, cols...), func(row func(...interface{})) {
+		for _, src := range sortedKeys(cm.Map) {
+			cells := []interface{}{src}
+			for _, dst := range cols {
+				if c, ok := cm.Map[src][dst]; ok {
+					cells = append(cells, c)
+				} else {
+					cells = append(cells, "-")
+				}
+			}
+			row(cells...)
+		}
+	})
+}
+
+func cmdEndpointCost(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("epcost")
+	id := fs.String("id", "", "resource id")
+	name := fs.String("type", "", "cost type name")
+	srcs := fs.String("srcs", "", "comma-separated source addresses")
+	dsts := fs.String("dsts", "", "comma-separated destination addresses")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("no cost type; use -type")
+	}
+	e, err := s.resource(ctx, *id, alto.MediaTypeEndpointCost, alto.MediaTypeEndpointCostParams, *name)
+	if err != nil {
+		return err
+	}
+	var params alto.ReqEndpointCostMap
+	params.CostType = e.CostTypes[*name]
+	if params.Endpoints.Srcs, err = parseAddrs(splitList(*srcs)); err != nil {
+		return err
+	}
+	if params.Endpoints.Dsts, err = parseAddrs(splitList(*dsts)); err != nil {
+		return err
+	}
+	if len(params.Endpoints.Dsts) == 0 {
+		return errors.New("no destination addresses; use -dsts")
+	}
+	ecm, err := s.c.EndpointCost(ctx, e.URI, &params)
+	if err != nil {
+		return err
+	}
+	if s.json {
+		return s.writeJSON(ecm)
+	}
+	return s.writeTable([]string{"SRC", "DST", "COST"}, func(row func(...interface{})) {
+		for _, src := range sortedKeys(ecm.Map) {
+			for _, dst := range sortedKeys(ecm.Map[src]) {
+				row(src, dst, ecm.Map[src][dst])
+			}
+		}
+	})
+}
+
+func cmdEndpointProp(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("epprop")
+	id := fs.String("id", "", "resource id")
+	props := fs.String("props", "", "comma-separated property names")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var params alto.ReqEndpointProp
+	if params.Properties = splitList(*props); len(params.Properties) == 0 {
+		return errors.New("no properties; use -props")
+	}
+	var err error
+	if params.Endpoints, err = parseAddrs(fs.Args()); err != nil {
+		return err
+	}
+	if len(params.Endpoints) == 0 {
+		return errors.New("no addresses")
+	}
+	e, err := s.resource(ctx, *id, alto.MediaTypeEndpointProp, alto.MediaTypeEndpointPropParams, "")
+	if err != nil {
+		return err
+	}
+	ep, err := s.c.EndpointProperty(ctx, e.URI, &params)
+	if err != nil {
+		return err
+	}
+	if s.json {
+		return s.writeJSON(ep)
+	}
+	return s.writeTable([]string{"ENDPOINT", "PROPERTY", "VALUE"}, func(row func(...interface{})) {
+		for _, addr := range sortedKeys(ep.Map) {
+			for _, prop := range sortedKeys(ep.Map[addr]) {
+				row(addr, prop, ep.Map[addr][prop])
+			}
+		}
+	})
+}
+
+func cmdLookup(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("lookup")
+	id := fs.String("id", "", "resource id")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: lookup [-id id] addr")
+	}
+	ep, err := parseAddr(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	nm, err := s.networkMap(ctx, *id)
+	if err != nil {
+		return err
+	}
+	pid, ok := nm.Lookup(ep)
+	if !ok {
+		return fmt.Errorf("no pid for %s", ep.TypedString())
+	}
+	if s.json {
+		return s.writeJSON(map[string]string{"endpoint": ep.TypedString(), "pid": pid, "map-vtag": nm.VersionTag})
+	}
+	return s.writeTable([]string{"ENDPOINT", "PID", "MAP-VTAG"}, func(row func(...interface{})) {
+		row(ep.TypedString(), pid, nm.VersionTag)
+	})
+}
+
+func cmdRank(ctx context.Context, s *session, args []string) error {
+	fs := newFlagSet("rank")
+	id := fs.String("id", "", "resource id")
+	name := fs.String("type", "", "cost type name")
+	strategy := fs.String("strategy", "mincost", "ranking strategy; mincost or weighted")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("no cost type; use -type")
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: rank [-id id] -type name [-strategy mincost|weighted] src addr...")
+	}
+	var st rank.Strategy
+	switch *strategy {
+	case "mincost":
+		st = rank.MinCost{}
+	case "weighted":
+		st = rank.WeightedRandom{}
+	default:
+		return fmt.Errorf("unknown strategy %s", *strategy)
+	}
+	eps, err := parseAddrs(fs.Args())
+	if err != nil {
+		return err
+	}
+	e, err := s.resource(ctx, *id, alto.MediaTypeEndpointCost, alto.MediaTypeEndpointCostParams, *name)
+	if err != nil {
+		return err
+	}
+	r := rank.NewRanker(&rank.ServiceSource{Client: s.c, URI: e.URI, CostType: e.CostTypes[*name]}, st)
+	cs, err := r.Rank(ctx, eps[0], eps[1:])
+	if err != nil {
+		return err
+	}
+	if s.json {
+		type candidate struct {
+			Endpoint string      `json:"endpoint"`
+			Cost     interface{} `json:"cost"`
+		}
+		var jcs []candidate
+		for _, c := range cs {
+			jc := candidate{Endpoint: c.Endpoint.TypedString()}
+			if c.Known {
+				jc.Cost = c.Cost
+			}
+			jcs = append(jcs, jc)
+		}
+		return s.writeJSON(jcs)
+	}
+	return s.writeTable([]string{"RANK", "ENDPOINT", "COST"}, func(row func(...interface{})) {
+		for i, c := range cs {
+			if c.Known {
+				row(i+1, c.Endpoint.TypedString(), c.Cost)
+			} else {
+				row(i+1, c.Endpoint.TypedString(), "-")
+			}
+		}
+	})
+}
+
+func (s *session) writeJSON(v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "%s\n", b)
+	return err
+}
+
+// writeTable writes a table with the header hdr and rows produced by
+// fn.
+func (s *session) writeTable(hdr []string, fn func(row func(...interface{}))) error {
+	tw := tabwriter.NewWriter(s.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(hdr, "\t"))
+	fn(func(cells ...interface{}) {
+		ss := make([]string, len(cells))
+		for i, c := range cells {
+			ss[i] = fmt.Sprint(c)
+		}
+		fmt.Fprintln(tw, strings.Join(ss, "\t"))
+	})
+	return tw.Flush()
+}
+
+// sortedKeys returns the sorted keys of the map m, which must have
+// string keys.
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,232 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Altoctl queries and inspects Application-Layer Traffic Optimization
+// (ALTO) servers.
+//
+// Usage:
+//
+//	altoctl [flags] command [arguments]
+//
+// The flags are:
+//
+//	-ird uri
+//		uri of information resource directory; defaults to $ALTO_IRD
+//	-o format
+//		output format; "table" or "json"
+//	-timeout duration
+//		timeout of each command
+//
+// The commands are:
+//
+//	ird
+//		list information resources
+//	netmap [-id id]
+//		show network map
+//	costmap [-id id] -type name [-srcs pid,...] [-dsts pid,...]
+//		show cost map, or filtered cost map when PIDs are given
+//	epcost [-id id] -type name -srcs addr,... -dsts addr,...
+//		show endpoint costs
+//	epprop [-id id] -props name,... addr...
+//		show endpoint properties
+//	lookup [-id id] addr
+//		show PID of address
+//	rank [-id id] -type name [-strategy mincost|weighted] src addr...
+//		rank candidate addresses by cost from source address
+//
+// Addresses are either plain or typed, such as 192.0.2.1 or
+// ipv6:2001:db8::1. The -id flag selects an information resource by
+// resource id when the directory lists more than one candidate.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/client"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "altoctl: %s\n", errorMessage(err))
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: altoctl [-ird uri] [-o table|json] [-timeout duration] command [arguments]")
+
+// A command represents a subcommand.
+type command func(ctx context.Context, s *session, args []string) error
+
+var commands = map[string]command{
+	"ird":     cmdIRD,
+	"netmap":  cmdNetworkMap,
+	"costmap": cmdCostMap,
+	"epcost":  cmdEndpointCost,
+	"epprop":  cmdEndpointProp,
+	"lookup":  cmdLookup,
+	"rank":    cmdRank,
+}
+
+// A session represents the state shared by subcommands.
+type session struct {
+	c    *client.Client
+	ird  string
+	json bool
+	w    io.Writer
+
+	cat client.Catalog // lazily retrieved
+}
+
+func run(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("altoctl", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	ird := fs.String("ird", os.Getenv("ALTO_IRD"), "uri of information resource directory")
+	format := fs.String("o", "table", "output format; table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of each command")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%v\n%v", err, errUsage)
+	}
+	if fs.NArg() == 0 {
+		return errUsage
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %s\n%v", fs.Arg(0), errUsage)
+	}
+	if *ird == "" {
+		return errors.New("no information resource directory; use -ird or $ALTO_IRD")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown output format %s", *format)
+	}
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	s := &session{c: client.New(), ird: *ird, json: *format == "json", w: w}
+	if err := cmd(ctx, s, fs.Args()[1:]); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	return nil
+}
+
+func (s *session) catalog(ctx context.Context) (client.Catalog, error) {
+	if s.cat != nil {
+		return s.cat, nil
+	}
+	cat, err := s.c.WalkDirectory(ctx, s.ird, 8)
+	if err != nil {
+		return nil, err
+	}
+	s.cat = cat
+	return cat, nil
+}
+
+// resource returns the information resource with media type
+// mediaType and acceptable request body accepts. The resource must
+// provide the cost type name unless name is empty, and must have the
+// resource id unless id is empty.
+func (s *session) resource(ctx context.Context, id, mediaType, accepts, name string) (*client.CatalogEntry, error) {
+	cat, err := s.catalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cat {
+		e := &cat[i]
+		if e.MediaType != mediaType || e.Accepts != accepts || id != "" && e.ID != id {
+			continue
+		}
+		if name != "" {
+			if _, ok := e.CostTypes[name]; !ok {
+				continue
+			}
+		}
+		return e, nil
+	}
+	desc := mediaType
+	if accepts != "" {
+		desc += " accepting " + accepts
+	}
+	if name != "" {
+		desc += " for cost type " + name
+	}
+	if id != "" {
+		desc += " with id " + id
+	}
+	return nil, fmt.Errorf("no %s in directory", desc)
+}
+
+// parseAddr parses s as a plain or typed address.
+func parseAddr(s string) (alto.Endpoint, error) {
+	typ := "ipv4"
+	if i := strings.Index(s, ":"); i > 0 {
+		switch p := s[:i]; p {
+		case "ipv4", "ipv6", "mac-48", "mac-64":
+			typ = p
+		default:
+			typ = "ipv6"
+		}
+	}
+	ep, err := alto.ParseEndpoint(typ, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %v", s, err)
+	}
+	return ep, nil
+}
+
+func parseAddrs(ss []string) ([]alto.Endpoint, error) {
+	var eps []alto.Endpoint
+	for _, s := range ss {
+		ep, err := parseAddr(s)
+		if err != nil {
+			return nil, err
+		}
+		eps = append(eps, ep)
+	}
+	return eps, nil
+}
+
+// splitList splits the comma-separated list s.
+func splitList(s string) []string {
+	var ss []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ss = append(ss, f)
+		}
+	}
+	return ss
+}
+
+var errorDescriptions = map[string]string{
+	alto.ErrSyntax:              "syntax error in request",
+	alto.ErrJSONFieldMissing:    "required JSON field missing in request",
+	alto.ErrJSONValueType:       "JSON field value of wrong type in request",
+	alto.ErrInvalidCostMode:     "cost mode not supported by resource",
+	alto.ErrInvalidCostMetric:   "cost metric not supported by resource",
+	alto.ErrInvalidPropertyType: "property type not supported by resource",
+}
+
+// errorMessage returns a readable message for err. Error notifications
+// returned by servers are explained with their error codes.
+func errorMessage(err error) string {
+	var e *alto.Error
+	if !errors.As(err, &e) {
+		return err.Error()
+	}
+	desc, ok := errorDescriptions[e.Code]
+	if !ok {
+		desc = "unknown error"
+	}
+	return fmt.Sprintf("%v (%s)", err, desc)
+}
@@ -0,0 +1,137 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+func loadTestResource(t *testing.T, name, typ string) *alto.Resource {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	r := alto.NewResource(typ)
+	if err := json.NewDecoder(f).Decode(r); err != nil {
+		t.Fatalf("json.Decoder.Decode failed: %v", err)
+	}
+	return r
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	rt := alto.CostType{CostMetric: "routingcost", CostMode: alto.CostModeNumerical}
+	b := alto.NewDirectoryBuilder()
+	b.AddCostType("num-routing", rt)
+	for _, dr := range []alto.DirectoryResource{
+		{ID: "my-network-map", URI: "/networkmap", MediaType: alto.MediaTypeNetworkMap},
+		{ID: "my-cost-map", URI: "/costmap", MediaType: alto.MediaTypeCostMap, Capabilities: &alto.CostMapCapabilities{CostTypeNames: []string{"num-routing"}}, Uses: []string{"my-network-map"}},
+		{ID: "my-filtered-cost-map", URI: "/costmap/filtered", MediaType: alto.MediaTypeCostMap, Accepts: alto.MediaTypeCostMapFilter, Capabilities: &alto.FilteredCostMapCapabilities{CostTypeNames: []string{"num-routing"}}, Uses: []string{"my-network-map"}},
+		{ID: "my-endpoint-cost", URI: "/endpointcost", MediaType: alto.MediaTypeEndpointCost, Accepts: alto.MediaTypeEndpointCostParams, Capabilities: &alto.FilteredCostMapCapabilities{CostTypeNames: []string{"num-routing"}}},
+	} {
+		if err := b.AddResource(dr); err != nil {
+			t.Fatalf("DirectoryBuilder.AddResource failed: %v", err)
+		}
+	}
+	dir, err := b.Build()
+	if err != nil {
+		t.Fatalf("DirectoryBuilder.Build failed: %v", err)
+	}
+	nm := loadTestResource(t, "../../testdata/resource-networkmap.js", "networkmap")
+	cm := loadTestResource(t, "../../testdata/resource-costmap.js", "costmap")
+
+	mux := http.NewServeMux()
+	for _, r := range []struct {
+		path, mediaType string
+		v               interface{}
+	}{
+		{"/directory", alto.MediaTypeDirectory, dir},
+		{"/networkmap", alto.MediaTypeNetworkMap, nm},
+		{"/costmap", alto.MediaTypeCostMap, cm},
+	} {
+		v := r.v
+		mux.Handle(r.path, &alto.Handler{MediaType: r.mediaType, Resource: func() (interface{}, time.Time) { return v, time.Time{} }})
+	}
+	mux.HandleFunc("/costmap/filtered", func(w http.ResponseWriter, req *http.Request) {
+		alto.WriteError(w, http.StatusBadRequest, alto.ErrInvalidCostMode)
+	})
+	mux.HandleFunc("/endpointcost", func(w http.ResponseWriter, req *http.Request) {
+		if !alto.Negotiate(w, req, alto.MediaTypeEndpointCost, alto.MediaTypeEndpointCostParams) {
+			return
+		}
+		var params struct {
+			Endpoints struct {
+				Srcs []string `json:"srcs"`
+				Dsts []string `json:"dsts"`
+			} `json:"endpoints"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+			alto.WriteError(w, http.StatusBadRequest, alto.ErrSyntax)
+			return
+		}
+		r := alto.NewResource("endpointcost")
+		ecm := r.Data.(*alto.EndpointCostMap)
+		ecm.CostType = rt
+		dcs := make(alto.EndpointDstCosts)
+		for i, dst := range params.Endpoints.Dsts {
+			dcs[dst] = float64(len(params.Endpoints.Dsts) - i)
+		}
+		ecm.Map[params.Endpoints.Srcs[0]] = dcs
+		w.Header().Set("Content-Type", alto.MediaTypeEndpointCost)
+		json.NewEncoder(w).Encode(r)
+	})
+	return httptest.NewServer(mux)
+}
+
+var runTests = []struct {
+	args []string
+	out  []string // substrings of output
+	err  string   // substring of error message
+}{
+	{[]string{"ird"}, []string{"my-filtered-cost-map", "/costmap/filtered", "num-routing"}, ""},
+	{[]string{"netmap"}, []string{"pid1", "192.0.2.0/24"}, ""},
+	{[]string{"-o", "json", "netmap"}, []string{`"map-vtag": "1266506139"`}, ""},
+	{[]string{"lookup", "192.0.2.1"}, []string{"ipv4:192.0.2.1", "pid1"}, ""},
+	{[]string{"lookup", "ipv6:2001:db8::1"}, []string{"ipv6:2001:db8::1", "pid3"}, ""},
+	{[]string{"costmap", "-type", "num-routing"}, []string{"SRC \\ DST", "pid3"}, ""},
+	{[]string{"costmap", "-type", "num-hop"}, nil, "no application/alto-costmap+json for cost type num-hop"},
+	{[]string{"costmap", "-type", "num-routing", "-srcs", "pid1"}, nil, "E_INVALID_COST_MODE (cost mode not supported by resource)"},
+	{[]string{"rank", "-type", "num-routing", "192.0.2.1", "198.51.100.1", "198.51.100.2"}, []string{"1     ipv4:198.51.100.2  1"}, ""},
+	{[]string{"bogus"}, nil, "unknown command bogus"},
+}
+
+func TestRun(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	for _, tt := range runTests {
+		var b bytes.Buffer
+		err := run(context.Background(), append([]string{"-ird", ts.URL + "/directory"}, tt.args...), &b)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(errorMessage(err), tt.err) {
+				t.Fatalf("%v: got %v; expected %v", tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%v: run failed: %v", tt.args, errorMessage(err))
+		}
+		for _, s := range tt.out {
+			if !strings.Contains(b.String(), s) {
+				t.Fatalf("%v: got %q; expected %q", tt.args, b.String(), s)
+			}
+		}
+	}
+}
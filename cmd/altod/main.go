@@ -0,0 +1,106 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Altod serves Application-Layer Traffic Optimization (ALTO)
+// information resources loaded from files.
+//
+// Usage:
+//
+//	altod -config altod.json
+//
+// The configuration file is a JSON object like the following:
+//
+//	{
+//		"listen": ":8080",
+//		"max-age": 60,
+//		"poll-interval": 10,
+//		"directory": {"path": "/directory", "file": "directory.json"},
+//		"network-maps": [
+//			{"path": "/networkmap", "file": "networkmap.json"}
+//		],
+//		"cost-maps": [
+//			{"path": "/costmap/num/routingcost", "file": "costmap.json"}
+//		]
+//	}
+//
+// Relative file names are resolved against the directory of the
+// configuration file. Map files contain either a bare map or a map
+// wrapped in an information resource with "meta" and "data" members.
+// A cost map names the path of the network map it depends on with a
+// "uses" member, which may be omitted when there is only one network
+// map. The version tag of a cost map must be the version tag of the
+// network map it depends on, and a network map that changes must
+// change its version tag.
+//
+// Altod reloads all files on SIGHUP, and when any file modification
+// is detected by polling every poll-interval seconds. A reload that
+// fails leaves the previously loaded files in service. The /healthz
+// endpoint reports that the process is alive, and the /readyz
+// endpoint reports whether the files have been loaded.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var configFile = flag.String("config", "altod.json", "configuration file")
+
+func main() {
+	flag.Parse()
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := newServer(cfg)
+	if err := srv.reload(); err != nil {
+		log.Printf("initial load failed: %v", err)
+	}
+	hs := &http.Server{Addr: cfg.Listen, Handler: srv}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	var poll <-chan time.Time
+	if cfg.PollInterval > 0 {
+		t := time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
+		defer t.Stop()
+		poll = t.C
+	}
+	done := make(chan struct{}) // closed when shutdown completes
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				if s != syscall.SIGHUP {
+					ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+					if err := hs.Shutdown(ctx); err != nil {
+						log.Printf("shutdown failed: %v", err)
+					}
+					cancel()
+					close(done)
+					return
+				}
+			case <-poll:
+				if !srv.modified() {
+					continue
+				}
+			}
+			if err := srv.reload(); err != nil {
+				log.Printf("reload failed: %v", err)
+			} else {
+				log.Printf("reloaded")
+			}
+		}
+	}()
+	if err := hs.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
@@ -0,0 +1,311 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+type config struct {
+	Listen       string           `json:"listen"`
+	MaxAge       int              `json:"max-age"`       // max-age directive of Cache-Control in seconds
+	PollInterval int              `json:"poll-interval"` // interval of file modification checks in seconds
+	Directory    *resourceConfig  `json:"directory"`
+	NetworkMaps  []resourceConfig `json:"network-maps"`
+	CostMaps     []resourceConfig `json:"cost-maps"`
+}
+
+type resourceConfig struct {
+	Path string `json:"path"` // url path
+	File string `json:"file"`
+	Uses string `json:"uses,omitempty"` // url path of network map that cost map depends on
+}
+
+func loadConfig(name string) (*config, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cfg config
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = ":8080"
+	}
+	dir := filepath.Dir(name)
+	paths := make(map[string]bool)
+	for _, rc := range cfg.resources() {
+		if rc.Path == "" || rc.File == "" {
+			return nil, fmt.Errorf("%s: resource without path or file", name)
+		}
+		if paths[rc.Path] {
+			return nil, fmt.Errorf("%s: duplicate path %s", name, rc.Path)
+		}
+		paths[rc.Path] = true
+		if !filepath.IsAbs(rc.File) {
+			rc.File = filepath.Join(dir, rc.File)
+		}
+	}
+	nms := make(map[string]bool)
+	for _, rc := range cfg.NetworkMaps {
+		nms[rc.Path] = true
+	}
+	for i := range cfg.CostMaps {
+		rc := &cfg.CostMaps[i]
+		if rc.Uses == "" && len(cfg.NetworkMaps) == 1 {
+			rc.Uses = cfg.NetworkMaps[0].Path
+		}
+		if !nms[rc.Uses] {
+			return nil, fmt.Errorf("%s: cost map %s uses no network map", name, rc.Path)
+		}
+	}
+	return &cfg, nil
+}
+
+// resources returns the configurations of all information resources.
+func (cfg *config) resources() []*resourceConfig {
+	var rcs []*resourceConfig
+	if cfg.Directory != nil {
+		rcs = append(rcs, cfg.Directory)
+	}
+	for i := range cfg.NetworkMaps {
+		rcs = append(rcs, &cfg.NetworkMaps[i])
+	}
+	for i := range cfg.CostMaps {
+		rcs = append(rcs, &cfg.CostMaps[i])
+	}
+	return rcs
+}
+
+// A resource represents an information resource encoded for serving.
+type resource struct {
+	er      *alto.EncodedResource
+	modTime time.Time
+}
+
+// A state represents a set of information resources encoded for
+// serving, keyed by url path.
+type state map[string]resource
+
+type server struct {
+	cfg    *config
+	mux    *http.ServeMux
+	stores map[string]*alto.Store // keyed by url path of network map
+
+	mu       sync.Mutex           // serializes reloads
+	modTimes map[string]time.Time // modification times of loaded files
+	st       atomic.Value         // state
+}
+
+func newServer(cfg *config) *server {
+	srv := &server{cfg: cfg, mux: http.NewServeMux(), stores: make(map[string]*alto.Store)}
+	maxAge := time.Duration(cfg.MaxAge) * time.Second
+	if cfg.Directory != nil {
+		srv.handle(cfg.Directory.Path, alto.MediaTypeDirectory, maxAge)
+	}
+	for _, rc := range cfg.NetworkMaps {
+		srv.stores[rc.Path] = alto.NewStore()
+		srv.handle(rc.Path, alto.MediaTypeNetworkMap, maxAge)
+	}
+	for _, rc := range cfg.CostMaps {
+		srv.handle(rc.Path, alto.MediaTypeCostMap, maxAge)
+	}
+	srv.mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	srv.mux.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		if srv.state() == nil {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	return srv
+}
+
+func (srv *server) handle(path, mediaType string, maxAge time.Duration) {
+	h := &alto.Handler{
+		MediaType: mediaType,
+		MaxAge:    maxAge,
+		Resource: func() (interface{}, time.Time) {
+			r := srv.state()[path]
+			return r.er, r.modTime
+		},
+	}
+	srv.mux.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
+		if srv.state() == nil {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (srv *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	srv.mux.ServeHTTP(w, req)
+}
+
+func (srv *server) state() state {
+	st, _ := srv.st.Load().(state)
+	return st
+}
+
+// reload loads all files, publishes the network maps and their
+// dependent cost maps through the stores, and replaces the served
+// resources, which are encoded once here, at once. Cost maps are
+// published keyed by url path. A reload that fails publishes nothing.
+func (srv *server) reload() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	loaded := make(map[string]interface{})
+	modTimes := make(map[string]time.Time)
+	load := func(rc resourceConfig, typ string) error {
+		fi, err := os.Stat(rc.File)
+		if err != nil {
+			return err
+		}
+		v, err := loadResource(rc.File, typ)
+		if err != nil {
+			return err
+		}
+		loaded[rc.Path] = v
+		modTimes[rc.File] = fi.ModTime()
+		return nil
+	}
+	if srv.cfg.Directory != nil {
+		if err := load(*srv.cfg.Directory, "directory"); err != nil {
+			return err
+		}
+	}
+	for _, rc := range srv.cfg.NetworkMaps {
+		if err := load(rc, "networkmap"); err != nil {
+			return err
+		}
+	}
+	for _, rc := range srv.cfg.CostMaps {
+		if err := load(rc, "costmap"); err != nil {
+			return err
+		}
+	}
+
+	// Check and encode everything before publishing anything, so
+	// that a failed reload leaves the stores and the served
+	// resources untouched.
+	cms := make(map[string]map[string]*alto.CostMap)
+	for _, rc := range srv.cfg.NetworkMaps {
+		nm := loaded[rc.Path].(*alto.Resource).Data.(*alto.NetworkMap)
+		if ss := srv.stores[rc.Path].Load(); ss != nil && ss.NetworkMap.VersionTag == nm.VersionTag {
+			vtag1, err := alto.ContentVersionTag(ss.NetworkMap)
+			if err != nil {
+				return fmt.Errorf("%s: %v", rc.File, err)
+			}
+			vtag2, err := alto.ContentVersionTag(nm)
+			if err != nil {
+				return fmt.Errorf("%s: %v", rc.File, err)
+			}
+			if vtag1 != vtag2 {
+				return fmt.Errorf("%s: network map changed without changing version tag %q", rc.File, nm.VersionTag)
+			}
+		}
+		cms[rc.Path] = make(map[string]*alto.CostMap)
+	}
+	for _, rc := range srv.cfg.CostMaps {
+		cm := loaded[rc.Path].(*alto.Resource).Data.(*alto.CostMap)
+		nm := loaded[rc.Uses].(*alto.Resource).Data.(*alto.NetworkMap)
+		if cm.VersionTag != nm.VersionTag {
+			return fmt.Errorf("%s: cost map depends on version tag %q instead of %q", rc.File, cm.VersionTag, nm.VersionTag)
+		}
+		cms[rc.Uses][rc.Path] = cm
+	}
+
+	st := make(state)
+	for _, rc := range srv.cfg.resources() {
+		er, err := alto.Encode(loaded[rc.Path])
+		if err != nil {
+			return fmt.Errorf("%s: %v", rc.File, err)
+		}
+		st[rc.Path] = resource{er: er, modTime: modTimes[rc.File]}
+	}
+
+	// Nothing below fails once the checks above have passed.
+	for _, rc := range srv.cfg.NetworkMaps {
+		s := srv.stores[rc.Path]
+		nm := loaded[rc.Path].(*alto.Resource).Data.(*alto.NetworkMap)
+		var err error
+		if ss := s.Load(); ss != nil && ss.NetworkMap.VersionTag == nm.VersionTag {
+			_, err = s.PublishCostMaps(cms[rc.Path])
+		} else {
+			_, err = s.Publish(nm, cms[rc.Path])
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", rc.File, err)
+		}
+	}
+	srv.st.Store(st)
+	srv.modTimes = modTimes
+	return nil
+}
+
+// modified reports whether any file has been modified since the last
+// successful reload.
+func (srv *server) modified() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.modTimes == nil {
+		return true
+	}
+	for name, modTime := range srv.modTimes {
+		fi, err := os.Stat(name)
+		if err != nil || !fi.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadResource loads the information resource of type typ from the
+// file name.
+func loadResource(name, typ string) (interface{}, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if typ == "directory" {
+		var dir alto.Directory
+		if err := json.Unmarshal(b, &dir); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return &dir, nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	r := alto.NewResource(typ)
+	if _, ok := raw["data"]; ok {
+		err = json.Unmarshal(b, r)
+	} else {
+		r.Meta = make(alto.Meta)
+		err = json.Unmarshal(b, r.Data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,203 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+const testConfig = `{
+	"max-age": 60,
+	"directory": {"path": "/directory", "file": "directory.js"},
+	"network-maps": [{"path": "/networkmap", "file": "networkmap.js"}],
+	"cost-maps": [{"path": "/costmap", "file": "resource-costmap.js"}]
+}`
+
+func setupTestServer(t *testing.T) (string, *server) {
+	dir, err := ioutil.TempDir("", "altod")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed: %v", err)
+	}
+	for _, name := range []string{"directory.js", "networkmap.js", "resource-costmap.js"} {
+		b, err := ioutil.ReadFile(filepath.Join("../../testdata", name))
+		if err != nil {
+			t.Fatalf("ioutil.ReadFile failed: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile failed: %v", err)
+		}
+	}
+	name := filepath.Join(dir, "altod.json")
+	if err := ioutil.WriteFile(name, []byte(testConfig), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile failed: %v", err)
+	}
+	cfg, err := loadConfig(name)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	return dir, newServer(cfg)
+}
+
+func serve(srv *server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	dir, srv := setupTestServer(t)
+	defer os.RemoveAll(dir)
+
+	if rec := serve(srv, "/healthz"); rec.Code != http.StatusOK {
+		t.Fatalf("got %v; expected %v", rec.Code, http.StatusOK)
+	}
+	for _, path := range []string{"/readyz", "/networkmap"} {
+		if rec := serve(srv, path); rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: got %v; expected %v", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+	if !srv.modified() {
+		t.Fatal("got unmodified; expected modified before initial load")
+	}
+	if err := srv.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if srv.modified() {
+		t.Fatal("got modified; expected unmodified after reload")
+	}
+	for _, tt := range []struct {
+		path, mediaType string
+	}{
+		{"/readyz", "text/plain; charset=utf-8"},
+		{"/directory", alto.MediaTypeDirectory},
+		{"/networkmap", alto.MediaTypeNetworkMap},
+		{"/costmap", alto.MediaTypeCostMap},
+	} {
+		rec := serve(srv, tt.path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %v; expected %v", tt.path, rec.Code, http.StatusOK)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != tt.mediaType {
+			t.Fatalf("%s: got %v; expected %v", tt.path, ct, tt.mediaType)
+		}
+	}
+
+	r := alto.NewResource("networkmap")
+	if err := json.Unmarshal(serve(srv, "/networkmap").Body.Bytes(), r); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	nm := r.Data.(*alto.NetworkMap)
+	r = alto.NewResource("costmap")
+	if err := json.Unmarshal(serve(srv, "/costmap").Body.Bytes(), r); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	cm := r.Data.(*alto.CostMap)
+	etag := serve(srv, "/costmap").Header().Get("ETag")
+
+	cm.Map["pid1"]["pid1"] = 2
+	writeTestFile(t, dir, "resource-costmap.js", cm)
+	if err := srv.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if s := serve(srv, "/costmap").Header().Get("ETag"); s == etag {
+		t.Fatalf("got same entity tag %v for updated cost map", s)
+	}
+
+	nm.VersionTag, cm.VersionTag = "2", "2"
+	writeTestFile(t, dir, "networkmap.js", nm)
+	name := filepath.Join(dir, "networkmap.js")
+	future := time.Now().Add(time.Hour)
+	os.Chtimes(name, future, future)
+	if !srv.modified() {
+		t.Fatal("got unmodified; expected modified")
+	}
+	if err := srv.reload(); err == nil {
+		t.Fatal("reload succeeded; expected failure for cost map depending on another version")
+	}
+	writeTestFile(t, dir, "resource-costmap.js", cm)
+	if err := srv.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if etag := serve(srv, "/networkmap").Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf("got %v; expected %v", etag, `"2"`)
+	}
+
+	delete(nm.Map, "pid3")
+	writeTestFile(t, dir, "networkmap.js", nm)
+	if err := srv.reload(); err == nil {
+		t.Fatal("reload succeeded; expected failure for network map changed without version tag change")
+	}
+	if err := ioutil.WriteFile(name, []byte("{"), 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile failed: %v", err)
+	}
+	if err := srv.reload(); err == nil {
+		t.Fatal("reload succeeded; expected failure")
+	}
+	if etag := serve(srv, "/networkmap").Header().Get("ETag"); etag != `"2"` {
+		t.Fatalf("got %v; expected %v", etag, `"2"`)
+	}
+	if ss := srv.stores["/networkmap"].Load(); ss.NetworkMap.VersionTag != "2" || ss.CostMap("/costmap") == nil {
+		t.Fatalf("got %v; expected network map version 2 and its cost map", ss)
+	}
+}
+
+func writeTestFile(t *testing.T, dir, name string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile failed: %v", err)
+	}
+}
+
+func TestServerReloadMalformed(t *testing.T) {
+	dir, srv := setupTestServer(t)
+	defer os.RemoveAll(dir)
+
+	if err := srv.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	before := serve(srv, "/costmap")
+	ss := srv.stores["/networkmap"].Load()
+
+	r := alto.NewResource("networkmap")
+	if err := json.Unmarshal(serve(srv, "/networkmap").Body.Bytes(), r); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	nm := r.Data.(*alto.NetworkMap)
+	nm.VersionTag = "2"
+	writeTestFile(t, dir, "networkmap.js", nm)
+	for _, in := range []string{
+		`{"cost-type": "x", "map-vtag": "2", "map": {}}`,
+		`{"cost-type": {"cost-mode": "numerical", "cost-metric": "routingcost"}, "map-vtag": "2", "map": []}`,
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "resource-costmap.js"), []byte(in), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile failed: %v", err)
+		}
+		if err := srv.reload(); err == nil {
+			t.Fatalf("reload succeeded for %s", in)
+		}
+		after := serve(srv, "/costmap")
+		if after.Code != http.StatusOK || after.Body.String() != before.Body.String() || after.Header().Get("ETag") != before.Header().Get("ETag") {
+			t.Fatalf("got %v %s; expected previously loaded cost map", after.Code, after.Body.Bytes())
+		}
+		if etag := serve(srv, "/networkmap").Header().Get("ETag"); etag == `"2"` {
+			t.Fatalf("got %v; expected previously loaded network map", etag)
+		}
+		if srv.stores["/networkmap"].Load() != ss {
+			t.Fatal("failed reload published a snapshot")
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/mikioh/alto"
+)
+
+var addrTypes = map[string]bool{"ipv4": true, "ipv6": true, "mac-48": true, "mac-64": true}
+
+// parseTypedAddr parses s as a typed address such as ipv4:192.0.2.1.
+func parseTypedAddr(s string) (alto.Endpoint, error) {
+	i := strings.Index(s, ":")
+	if i < 0 || !addrTypes[s[:i]] {
+		return nil, errors.New("missing address type")
+	}
+	return parseAddr(s[:i], s[i+1:])
+}
+
+// parseAddr parses addr as an address or address prefix of address
+// type typ.
+func parseAddr(typ, addr string) (alto.Endpoint, error) {
+	ep, err := alto.ParseEndpoint(typ, addr)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Network() != typ {
+		return nil, errors.New("address type mismatch")
+	}
+	return ep, nil
+}
+
+var pidRE = regexp.MustCompile(`^[0-9A-Za-z\-:@_.]*$`)
+
+// checkPID checks the syntax of the PID name pid at path.
+func (l *linter) checkPID(path, pid string) {
+	switch {
+	case len(pid) == 0 || len(pid) > 64:
+		l.errorf(path, "pid-name", "pid name %q must have 1 to 64 characters", pid)
+	case !pidRE.MatchString(pid):
+		l.errorf(path, "pid-name", "pid name %q contains invalid characters", pid)
+	case strings.Contains(pid, "."):
+		l.warnf(path, "pid-name", "pid name %q contains reserved separator '.'", pid)
+	}
+}
+
+// Cost metrics registered in the ALTO Cost Metric Registry.
+var costMetrics = map[string]bool{
+	"routingcost":     true,
+	"hopcount":        true,
+	"delay-ow":        true,
+	"delay-rt":        true,
+	"delay-variation": true,
+	"lossrate":        true,
+	"tput":            true,
+	"bw-residual":     true,
+	"bw-available":    true,
+	"bw-maxres":       true,
+}
+
+// Cost sources described in RFC 9439.
+var costSources = map[string]bool{
+	alto.CostSourceNominal:    true,
+	alto.CostSourceSLA:        true,
+	alto.CostSourceEstimation: true,
+}
+
+// checkCostType checks the cost type at path and returns its cost
+// mode.
+func (l *linter) checkCostType(path string, v interface{}) string {
+	ct := l.object(path, v, []string{"cost-mode", "cost-metric", "cost-source", "description"}, "cost-mode", "cost-metric")
+	if ct == nil {
+		return ""
+	}
+	var mode string
+	if v, ok := ct["cost-mode"]; ok {
+		p := member(path, "cost-mode")
+		if mode, ok = l.str(p, v); ok && mode != alto.CostModeNumerical && mode != alto.CostModeOrdinal {
+			l.errorf(p, "cost-mode", "unknown cost mode %q", mode)
+		}
+	}
+	if v, ok := ct["cost-metric"]; ok {
+		p := member(path, "cost-metric")
+		if metric, ok := l.str(p, v); ok && !costMetrics[metric] && !strings.HasPrefix(metric, "priv:") {
+			l.warnf(p, "cost-metric", "unregistered cost metric %q", metric)
+		}
+	}
+	if v, ok := ct["cost-source"]; ok {
+		p := member(path, "cost-source")
+		if src, ok := l.str(p, v); ok && !costSources[src] {
+			l.errorf(p, "cost-source", "unknown cost source %q", src)
+		}
+	}
+	if v, ok := ct["description"]; ok {
+		l.str(member(path, "description"), v)
+	}
+	return mode
+}
+
+// checkCost checks the cost v at path in cost mode mode.
+func (l *linter) checkCost(path, mode string, v interface{}) {
+	c, ok := l.number(path, v)
+	if ok && mode == alto.CostModeOrdinal && (c < 1 || c != math.Trunc(c)) {
+		l.errorf(path, "ordinal", "ordinal cost %v is not a positive integer", c)
+	}
+}
+
+func (l *linter) lintDirectory() {
+	root := l.f.root
+	raw := l.object(root, l.f.raw, []string{"meta", "resources"}, "resources")
+	cts := make(map[string]bool)
+	if v, ok := raw["meta"]; ok {
+		meta := l.object(member(root, "meta"), v, nil)
+		if v, ok := meta["cost-types"]; ok {
+			p := member(member(root, "meta"), "cost-types")
+			m := l.object(p, v, nil)
+			for _, name := range sortedKeys(m) {
+				cts[name] = true
+				l.checkCostType(member(p, name), m[name])
+			}
+		}
+	}
+	p := member(root, "resources")
+	rs, _ := l.array(p, raw["resources"])
+	ids := make(map[string]bool)
+	for i, v := range rs {
+		r, _ := v.(map[string]interface{})
+		if id, ok := r["id"].(string); ok {
+			if ids[id] {
+				l.errorf(member(index(p, i), "id"), "resource", "duplicate resource id %q", id)
+			}
+			ids[id] = true
+		}
+	}
+	for i, v := range rs {
+		rp := index(p, i)
+		r := l.object(rp, v, []string{"id", "uri", "media-type", "accepts", "capabilities", "uses"}, "uri", "media-type")
+		for _, name := range []string{"id", "uri", "media-type", "accepts"} {
+			if v, ok := r[name]; ok {
+				l.str(member(rp, name), v)
+			}
+		}
+		if v, ok := r["uses"]; ok {
+			up := member(rp, "uses")
+			uses, _ := l.array(up, v)
+			for j, v := range uses {
+				if id, ok := l.str(index(up, j), v); ok && !ids[id] {
+					l.errorf(index(up, j), "resource", "unknown resource id %q", id)
+				}
+			}
+		}
+		if v, ok := r["capabilities"]; ok {
+			cp := member(rp, "capabilities")
+			caps := l.object(cp, v, nil)
+			if v, ok := caps["cost-type-names"]; ok {
+				np := member(cp, "cost-type-names")
+				names, _ := l.array(np, v)
+				for j, v := range names {
+					if name, ok := l.str(index(np, j), v); ok && !cts[name] {
+						l.errorf(index(np, j), "cost-type", "cost type %q is not defined in meta", name)
+					}
+				}
+			}
+		}
+	}
+	var dir alto.Directory
+	if l.decodeData(&dir) {
+		l.f.dir = &dir
+	}
+}
+
+func (l *linter) lintNetworkMap() {
+	root := l.f.root
+	raw := l.object(root, l.f.raw, []string{"map-vtag", "map"}, "map-vtag", "map")
+	if v, ok := raw["map-vtag"]; ok {
+		l.str(member(root, "map-vtag"), v)
+	}
+	mp := member(root, "map")
+	locs := make(map[string][]string) // pid, address type and prefix to paths
+	m := l.object(mp, raw["map"], nil)
+	for _, pid := range sortedKeys(m) {
+		pp := member(mp, pid)
+		l.checkPID(pp, pid)
+		l.checkEndpointAddrGroup(pp, pid, m[pid], locs)
+	}
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	if !l.decodeData(nm) {
+		return
+	}
+	l.f.data = nm
+	loc := func(pid string, ep alto.Endpoint, nth int) string {
+		ps := locs[pid+" "+ep.Network()+" "+ep.String()]
+		if nth < len(ps) {
+			return ps[nth]
+		}
+		return mp
+	}
+	a := nm.Analyze()
+	for _, pc := range a.Duplicates {
+		if pc.PID == pc.OtherPID {
+			l.warnf(loc(pc.PID, pc.Prefix, 1), "duplicate-prefix", "prefix %s appears more than once in %s", pc.Prefix, pc.PID)
+		} else {
+			l.errorf(loc(pc.PID, pc.Prefix, 0), "duplicate-prefix", "prefix %s appears in both %s and %s", pc.Prefix, pc.PID, pc.OtherPID)
+		}
+	}
+	for _, pc := range a.Overlaps {
+		l.warnf(loc(pc.PID, pc.Prefix, 0), "overlapping-prefix", "prefix %s in %s overlaps %s in %s", pc.Prefix, pc.PID, pc.Other, pc.OtherPID)
+	}
+	for _, pc := range a.Shadows {
+		l.warnf(loc(pc.PID, pc.Prefix, 0), "shadowed-prefix", "prefix %s in %s shadows %s in %s", pc.Prefix, pc.PID, pc.Other, pc.OtherPID)
+	}
+}
+
+// checkEndpointAddrGroup checks the endpoint address group v of pid
+// at path, and records the paths of addresses in locs.
+func (l *linter) checkEndpointAddrGroup(path, pid string, v interface{}, locs map[string][]string) {
+	eag := l.object(path, v, nil)
+	for _, typ := range sortedKeys(eag) {
+		tp := member(path, typ)
+		if !addrTypes[typ] {
+			l.errorf(tp, "schema", "unknown address type %q", typ)
+			continue
+		}
+		addrs, _ := l.array(tp, eag[typ])
+		for i, v := range addrs {
+			s, ok := l.str(index(tp, i), v)
+			if !ok {
+				continue
+			}
+			ep, err := parseAddr(typ, s)
+			if err != nil {
+				l.errorf(index(tp, i), "address", "invalid %s address %q: %v", typ, s, err)
+				continue
+			}
+			key := pid + " " + typ + " " + ep.String()
+			locs[key] = append(locs[key], index(tp, i))
+		}
+	}
+}
+
+func (l *linter) lintEndpointAddrGroup() {
+	l.checkEndpointAddrGroup(l.f.root, "", l.f.raw, make(map[string][]string))
+}
+
+func (l *linter) lintDstCosts() {
+	for _, dst := range sortedKeys(l.f.raw) {
+		dp := member(l.f.root, dst)
+		l.checkPID(dp, dst)
+		l.checkCost(dp, "", l.f.raw[dst])
+	}
+}
+
+func (l *linter) lintCostMap() {
+	root := l.f.root
+	raw := l.object(root, l.f.raw, []string{"cost-type", "map-vtag", "map"}, "cost-type", "map-vtag", "map")
+	var mode string
+	if v, ok := raw["cost-type"]; ok {
+		mode = l.checkCostType(member(root, "cost-type"), v)
+	}
+	if v, ok := raw["map-vtag"]; ok {
+		l.str(member(root, "map-vtag"), v)
+	}
+	mp := member(root, "map")
+	m := l.object(mp, raw["map"], nil)
+	for _, src := range sortedKeys(m) {
+		sp := member(mp, src)
+		l.checkPID(sp, src)
+		dcs := l.object(sp, m[src], nil)
+		for _, dst := range sortedKeys(dcs) {
+			dp := member(sp, dst)
+			l.checkPID(dp, dst)
+			l.checkCost(dp, mode, dcs[dst])
+		}
+	}
+	cm := alto.NewResource("costmap").Data.(*alto.CostMap)
+	if l.decodeData(cm) {
+		l.f.data = cm
+	}
+}
+
+func (l *linter) lintEndpointCostMap() {
+	root := l.f.root
+	raw := l.object(root, l.f.raw, []string{"cost-type", "map"}, "cost-type", "map")
+	var mode string
+	if v, ok := raw["cost-type"]; ok {
+		mode = l.checkCostType(member(root, "cost-type"), v)
+	}
+	mp := member(root, "map")
+	m := l.object(mp, raw["map"], nil)
+	for _, src := range sortedKeys(m) {
+		sp := member(mp, src)
+		if _, err := parseTypedAddr(src); err != nil {
+			l.errorf(sp, "address", "invalid typed address %q: %v", src, err)
+		}
+		dcs := l.object(sp, m[src], nil)
+		for _, dst := range sortedKeys(dcs) {
+			dp := member(sp, dst)
+			if _, err := parseTypedAddr(dst); err != nil {
+				l.errorf(dp, "address", "invalid typed address %q: %v", dst, err)
+			}
+			l.checkCost(dp, mode, dcs[dst])
+		}
+	}
+}
+
+// crossCheck checks the consistency between directories, network maps
+// and cost maps in fs.
+func (l *linter) crossCheck(fs []*file) {
+	byName := make(map[string]*file)
+	rs := make(map[string]alto.Data)
+	dir := &alto.Directory{Meta: make(alto.Meta)}
+	var hasDir, hasNetworkMap bool
+	for _, f := range fs {
+		switch {
+		case f.dir != nil && !hasDir:
+			dir, hasDir = f.dir, true
+		case f.data != nil:
+			byName[f.name], rs[f.name] = f, f.data
+			if f.kind == "networkmap" {
+				hasNetworkMap = true
+			}
+		}
+	}
+	for _, inc := range alto.CheckDirectory(dir, rs) {
+		f, ok := byName[inc.URI]
+		if !ok {
+			continue // resources listed in directory are checked by lintDirectory
+		}
+		l.f = f
+		mp := member(f.root, "map")
+		switch inc.Kind {
+		case alto.UnknownCostType:
+			if hasDir {
+				l.errorf(member(f.root, "cost-type"), "cost-type", "cost type %s is not defined in directory", inc.Detail)
+			}
+		case alto.MissingNetworkMap:
+			if hasNetworkMap {
+				l.errorf(member(f.root, "map-vtag"), "version-tag", "no network map with version tag %q", inc.Detail)
+			}
+		case alto.VersionTagMismatch:
+			l.errorf(member(f.root, "map-vtag"), "version-tag", "version tag %s", inc.Detail)
+		case alto.UnknownPID:
+			l.errorf(pidPath(f, inc.PID), "unknown-pid", "pid %s is not in network map", inc.PID)
+		case alto.MissingCostRow:
+			l.warnf(mp, "missing-cost-row", "network map pid %s has no cost row", inc.PID)
+		}
+	}
+}
+
+// pidPath returns the JSONPath of the first occurrence of pid in the
+// cost map f.
+func pidPath(f *file, pid string) string {
+	mp := member(f.root, "map")
+	m, _ := f.raw["map"].(map[string]interface{})
+	if _, ok := m[pid]; ok {
+		return member(mp, pid)
+	}
+	for _, src := range sortedKeys(m) {
+		if dcs, ok := m[src].(map[string]interface{}); ok {
+			if _, ok := dcs[pid]; ok {
+				return member(member(mp, src), pid)
+			}
+		}
+	}
+	return mp
+}
@@ -0,0 +1,361 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/mikioh/alto"
+)
+
+type severity string
+
+const (
+	severityError   severity = "error"
+	severityWarning severity = "warning"
+)
+
+// A diagnostic represents a problem found in a file.
+type diagnostic struct {
+	File     string   `json:"file"`
+	Path     string   `json:"path"` // JSONPath of problematic value
+	Severity severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s: %s [%s]", d.File, d.Path, d.Severity, d.Message, d.Code)
+}
+
+// A file represents a linted file.
+type file struct {
+	name string
+	kind string                 // type of information resource or its fragment
+	root string                 // JSONPath of resource data
+	raw  map[string]interface{} // resource data
+	data alto.Data              // decoded network map or cost map
+	dir  *alto.Directory        // decoded directory
+}
+
+// A linter represents a linter that accumulates diagnostics.
+type linter struct {
+	f     *file // file being linted
+	diags []diagnostic
+}
+
+func (l *linter) report(path string, sev severity, code, format string, args ...interface{}) {
+	l.diags = append(l.diags, diagnostic{File: l.f.name, Path: path, Severity: sev, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) errorf(path, code, format string, args ...interface{}) {
+	l.report(path, severityError, code, format, args...)
+}
+
+func (l *linter) warnf(path, code, format string, args ...interface{}) {
+	l.report(path, severityWarning, code, format, args...)
+}
+
+// lintFiles lints the named files and returns diagnostics ordered by
+// file.
+func lintFiles(names []string) []diagnostic {
+	var l linter
+	var fs []*file
+	for _, name := range names {
+		l.f = &file{name: name}
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			l.errorf("$", "io", "%v", err)
+			continue
+		}
+		if l.lint(b) {
+			fs = append(fs, l.f)
+		}
+	}
+	l.crossCheck(fs)
+	order := make(map[string]int)
+	for i, name := range names {
+		if _, ok := order[name]; !ok {
+			order[name] = i
+		}
+	}
+	sort.SliceStable(l.diags, func(i, j int) bool {
+		return order[l.diags[i].File] < order[l.diags[j].File]
+	})
+	return l.diags
+}
+
+// lint lints the content b of the current file. It reports whether
+// the file has a known type.
+func (l *linter) lint(b []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	v, err := l.decode(dec, "$")
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = fmt.Errorf("trailing data after top-level value")
+		}
+	}
+	if err != nil {
+		if se, ok := err.(*json.SyntaxError); ok {
+			line, col := position(b, se.Offset)
+			err = fmt.Errorf("line %d, column %d: %v", line, col, se)
+		}
+		l.errorf("$", "syntax", "%v", err)
+		return false
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		l.errorf("$", "file-type", "expected object, got %s", jsonType(v))
+		return false
+	}
+	l.f.root, l.f.raw = "$", root
+	if _, ok := root["data"]; ok {
+		l.object("$", root, []string{"meta", "data"}, "meta", "data")
+		l.object(member("$", "meta"), root["meta"], nil)
+		if l.f.raw = l.object(member("$", "data"), root["data"], nil); l.f.raw == nil {
+			return false
+		}
+		l.f.root = member("$", "data")
+	}
+	switch l.f.kind = detect(l.f.raw); l.f.kind {
+	case "directory":
+		l.lintDirectory()
+	case "networkmap":
+		l.lintNetworkMap()
+	case "costmap":
+		l.lintCostMap()
+	case "endpointcost":
+		l.lintEndpointCostMap()
+	case "endpointaddrgroup":
+		l.lintEndpointAddrGroup()
+	case "dstcosts":
+		l.lintDstCosts()
+	default:
+		l.errorf(l.f.root, "file-type", "unknown information resource")
+		return false
+	}
+	return true
+}
+
+// detect returns the type of the information resource data raw. It
+// also detects endpoint address groups and destination costs, which
+// are fragments of network maps and cost maps.
+func detect(raw map[string]interface{}) string {
+	if _, ok := raw["resources"]; ok {
+		return "directory"
+	}
+	m, ok := raw["map"].(map[string]interface{})
+	if !ok {
+		return detectFragment(raw)
+	}
+	if _, ok := raw["cost-type"]; !ok {
+		return "networkmap"
+	}
+	for key := range m {
+		if _, err := parseTypedAddr(key); err == nil {
+			return "endpointcost"
+		}
+	}
+	return "costmap"
+}
+
+func detectFragment(raw map[string]interface{}) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	eag, dcs := true, true
+	for key, v := range raw {
+		if !addrTypes[key] {
+			eag = false
+		}
+		if _, ok := v.(float64); !ok {
+			dcs = false
+		}
+	}
+	switch {
+	case eag:
+		return "endpointaddrgroup"
+	case dcs:
+		return "dstcosts"
+	}
+	return ""
+}
+
+// decode decodes a JSON value at path from dec. Unlike
+// encoding/json, it reports duplicate object members.
+func (l *linter) decode(dec *json.Decoder, path string) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		m := make(map[string]interface{})
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			name := tok.(string)
+			p := member(path, name)
+			v, err := l.decode(dec, p)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := m[name]; ok {
+				l.errorf(p, "syntax", "duplicate member %q", name)
+			}
+			m[name] = v
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := l.decode(dec, index(path, len(a)))
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := dec.Token()
+		return a, err
+	}
+	return tok, nil
+}
+
+// position returns the line and column of the byte offset in b.
+func position(b []byte, offset int64) (int, int) {
+	if offset > int64(len(b)) {
+		offset = int64(len(b))
+	}
+	line, col := 1, 1
+	for _, c := range b[:offset] {
+		if c == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+var identRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// member returns the JSONPath of the member name of the object at
+// path.
+func member(path, name string) string {
+	if identRE.MatchString(name) {
+		return path + "." + name
+	}
+	return path + "[" + strconv.Quote(name) + "]"
+}
+
+// index returns the JSONPath of the i-th element of the array at path.
+func index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// object checks that v at path is an object that has only the members
+// allowed, unless allowed is nil, and has all the members required.
+// It returns nil when v is not an object.
+func (l *linter) object(path string, v interface{}, allowed []string, required ...string) map[string]interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		l.errorf(path, "schema", "expected object, got %s", jsonType(v))
+		return nil
+	}
+	if allowed != nil {
+		known := make(map[string]bool)
+		for _, name := range allowed {
+			known[name] = true
+		}
+		for _, name := range sortedKeys(m) {
+			if !known[name] {
+				l.errorf(member(path, name), "schema", "unknown member %q", name)
+			}
+		}
+	}
+	for _, name := range required {
+		if _, ok := m[name]; !ok {
+			l.errorf(path, "schema", "missing member %q", name)
+		}
+	}
+	return m
+}
+
+// array checks that v at path is an array.
+func (l *linter) array(path string, v interface{}) ([]interface{}, bool) {
+	a, ok := v.([]interface{})
+	if !ok {
+		l.errorf(path, "schema", "expected array, got %s", jsonType(v))
+	}
+	return a, ok
+}
+
+// str checks that v at path is a string.
+func (l *linter) str(path string, v interface{}) (string, bool) {
+	s, ok := v.(string)
+	if !ok {
+		l.errorf(path, "schema", "expected string, got %s", jsonType(v))
+	}
+	return s, ok
+}
+
+// number checks that v at path is a number.
+func (l *linter) number(path string, v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	if !ok {
+		l.errorf(path, "schema", "expected number, got %s", jsonType(v))
+	}
+	return n, ok
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// decodeData decodes the resource data of the current file into v.
+// Data that cannot be decoded has already been reported by the schema
+// checks.
+func (l *linter) decodeData(v interface{}) bool {
+	b, err := json.Marshal(l.f.raw)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(b, v) == nil
+}
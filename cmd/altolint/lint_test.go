@@ -0,0 +1,125 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func lintTestFiles(t *testing.T, contents ...string) []diagnostic {
+	dir, err := ioutil.TempDir("", "altolint")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	var names []string
+	for i, s := range contents {
+		name := filepath.Join(dir, string('a'+rune(i))+".json")
+		if err := ioutil.WriteFile(name, []byte(s), 0644); err != nil {
+			t.Fatalf("ioutil.WriteFile failed: %v", err)
+		}
+		names = append(names, name)
+	}
+	diags := lintFiles(names)
+	for i := range diags {
+		diags[i].File = filepath.Base(diags[i].File)
+	}
+	return diags
+}
+
+type diagKey struct {
+	file, path, code string
+	sev              severity
+}
+
+var lintTests = []struct {
+	in    string
+	diags []diagKey
+}{
+	{
+		`{"map-vtag": "1", "map": {"pid1": {"ipv4": ["192.0.2.0/24"]}}}`,
+		nil,
+	},
+	{
+		`{"map-vtag": "1", "map": {"pid1": {"ipv4": ["192.0.2.0/24"]}}, "map": {}}`,
+		[]diagKey{{"a.json", "$.map", "syntax", severityError}},
+	},
+	{
+		`{"map-vtag": "1", "map": {"pid 1": {"ipv4": ["192.0.2.0/24", "2001:db8::/32"], "ipx": []}, "pid.2": {"ipv4": ["192.0.2.0/24"]}}, "extra": 1}`,
+		[]diagKey{
+			{"a.json", "$.extra", "schema", severityError},
+			{"a.json", `$.map["pid 1"]`, "pid-name", severityError},
+			{"a.json", `$.map["pid 1"].ipv4[1]`, "address", severityError},
+			{"a.json", `$.map["pid 1"].ipx`, "schema", severityError},
+			{"a.json", `$.map["pid.2"]`, "pid-name", severityWarning},
+			{"a.json", `$.map["pid.2"].ipv4[0]`, "duplicate-prefix", severityError},
+		},
+	},
+	{
+		`{"meta": {}, "data": {"cost-type": {"cost-mode": "ordinal", "cost-metric": "bandwidth", "cost-source": "measured"}, "map-vtag": "1", "map": {"pid1": {"pid1": 1, "pid2": 1.5, "pid3": "2"}}}}`,
+		[]diagKey{
+			{"a.json", `$.data["cost-type"]["cost-metric"]`, "cost-metric", severityWarning},
+			{"a.json", `$.data["cost-type"]["cost-source"]`, "cost-source", severityError},
+			{"a.json", `$.data.map.pid1.pid2`, "ordinal", severityError},
+			{"a.json", `$.data.map.pid1.pid3`, "schema", severityError},
+		},
+	},
+	{
+		`{"resources": [{"id": "nm", "uri": "/nm", "media-type": "application/alto-networkmap+json"}, {"id": "cm", "uri": "/cm", "media-type": "application/alto-costmap+json", "uses": ["nm2"], "capabilities": {"cost-type-names": ["num-routing"]}}]}`,
+		[]diagKey{
+			{"a.json", "$.resources[1].uses[0]", "resource", severityError},
+			{"a.json", `$.resources[1].capabilities["cost-type-names"][0]`, "cost-type", severityError},
+		},
+	},
+	{
+		`{"cost-type": "x", "map-vtag": "1", "map": {}}`,
+		[]diagKey{{"a.json", `$["cost-type"]`, "schema", severityError}},
+	},
+	{
+		`{"map-vtag": "1", "map": {}`,
+		[]diagKey{{"a.json", "$", "syntax", severityError}},
+	},
+}
+
+func TestLint(t *testing.T) {
+	for i, tt := range lintTests {
+		diags := lintTestFiles(t, tt.in)
+		checkDiagnostics(t, i, diags, tt.diags)
+	}
+}
+
+func TestLintCrossFile(t *testing.T) {
+	diags := lintTestFiles(t,
+		`{"meta": {"cost-types": {"num-routing": {"cost-mode": "numerical", "cost-metric": "routingcost"}}}, "resources": []}`,
+		`{"map-vtag": "1", "map": {"pid1": {"ipv4": ["192.0.2.0/24"]}, "pid2": {"ipv4": ["198.51.100.0/24"]}}}`,
+		`{"cost-type": {"cost-mode": "numerical", "cost-metric": "routingcost"}, "map-vtag": "1", "map": {"pid1": {"pid1": 0, "pid3": 1}}}`,
+		`{"cost-type": {"cost-mode": "numerical", "cost-metric": "hopcount"}, "map-vtag": "2", "map": {"pid1": {"pid1": 0}}}`,
+	)
+	checkDiagnostics(t, 0, diags, []diagKey{
+		{"c.json", "$.map", "missing-cost-row", severityWarning},
+		{"c.json", "$.map.pid1.pid3", "unknown-pid", severityError},
+		{"d.json", `$["cost-type"]`, "cost-type", severityError},
+		{"d.json", `$["map-vtag"]`, "version-tag", severityError},
+		{"d.json", "$.map", "missing-cost-row", severityWarning},
+	})
+}
+
+func checkDiagnostics(t *testing.T, i int, diags []diagnostic, expected []diagKey) {
+	got := make(map[diagKey]bool)
+	for _, d := range diags {
+		got[diagKey{d.File, d.Path, d.Code, d.Severity}] = true
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("#%d: got %v; expected %v", i, diags, expected)
+	}
+	for _, k := range expected {
+		if !got[k] {
+			t.Fatalf("#%d: got %v; expected %v", i, diags, k)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Altolint validates Application-Layer Traffic Optimization (ALTO)
+// JSON files.
+//
+// Usage:
+//
+//	altolint [-json] [-werror] file...
+//
+// Altolint detects whether each file holds an information resource
+// directory, a network map, a cost map or an endpoint cost map, either
+// bare or wrapped in an information resource with "meta" and "data"
+// members, or a fragment such as an endpoint address group or a set
+// of destination costs. It decodes each file strictly and reports unknown or
+// duplicate members, values of wrong types, malformed PID names and
+// addresses, duplicate and overlapping prefixes, unregistered cost
+// modes and metrics, and invalid ordinal costs. Across files, it
+// reports cost maps depending on missing or other versions of network
+// maps, and cost types missing from the directory.
+//
+// Each diagnostic is located by a JSONPath expression. With -json,
+// diagnostics are written as JSON objects, one per line. Altolint
+// exits with status 1 when any error is found, or any warning is found
+// with -werror, and with status 2 on usage errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+var (
+	jsonOutput = flag.Bool("json", false, "write diagnostics as JSON lines")
+	werror     = flag.Bool("werror", false, "treat warnings as errors")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: altolint [-json] [-werror] file...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	diags := lintFiles(flag.Args())
+	if err := writeDiagnostics(os.Stdout, diags, *jsonOutput); err != nil {
+		fmt.Fprintf(os.Stderr, "altolint: %v\n", err)
+		os.Exit(2)
+	}
+	for _, d := range diags {
+		if d.Severity == severityError || *werror {
+			os.Exit(1)
+		}
+	}
+}
+
+func writeDiagnostics(w io.Writer, diags []diagnostic, jsonOutput bool) error {
+	enc := json.NewEncoder(w)
+	for _, d := range diags {
+		var err error
+		if jsonOutput {
+			err = enc.Encode(d)
+		} else {
+			_, err = fmt.Fprintln(w, d.String())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
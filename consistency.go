@@ -0,0 +1,219 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An InconsistencyKind represents a kind of inconsistency between
+// information resources.
+type InconsistencyKind int
+
+const (
+	UnknownPID         InconsistencyKind = iota // cost map refers to a PID missing from the network map
+	VersionTagMismatch                          // cost map depends on another version of the network map
+	MissingCostRow                              // network map PID has no cost row in the cost map
+	UnknownCostType                             // cost type is not listed in the directory
+	MissingNetworkMap                           // cost map has no network map to depend on
+)
+
+var inconsistencyKinds = map[InconsistencyKind]string{
+	UnknownPID:         "unknown pid",
+	VersionTagMismatch: "version tag mismatch",
+	MissingCostRow:     "missing cost row",
+	UnknownCostType:    "unknown cost type",
+	MissingNetworkMap:  "missing network map",
+}
+
+func (k InconsistencyKind) String() string {
+	if s, ok := inconsistencyKinds[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("inconsistency kind %d", int(k))
+}
+
+// An Inconsistency represents an inconsistency found in the
+// information resource identified by URI.
+type Inconsistency struct {
+	Kind   InconsistencyKind
+	URI    string // uri of information resource
+	PID    string // provider-defined identifier, if any
+	Detail string
+}
+
+func (inc *Inconsistency) Error() string {
+	s := inc.URI + ": " + inc.Kind.String()
+	if inc.PID != "" {
+		s += " " + inc.PID
+	}
+	if inc.Detail != "" {
+		s += ": " + inc.Detail
+	}
+	return s
+}
+
+type byURI []*Inconsistency
+
+func (s byURI) Len() int      { return len(s) }
+func (s byURI) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byURI) Less(i, j int) bool {
+	if s[i].URI != s[j].URI {
+		return s[i].URI < s[j].URI
+	}
+	if s[i].Kind != s[j].Kind {
+		return s[i].Kind < s[j].Kind
+	}
+	if s[i].PID != s[j].PID {
+		return s[i].PID < s[j].PID
+	}
+	return s[i].Detail < s[j].Detail
+}
+
+// CostTypes returns a set of cost types defined in the "cost-types"
+// member of meta.
+func (meta Meta) CostTypes() map[string]CostType {
+	cts := make(map[string]CostType)
+	switch v := meta["cost-types"].(type) {
+	case map[string]CostType:
+		for name, ct := range v {
+			cts[name] = ct
+		}
+	case map[string]interface{}:
+		for name, vv := range v {
+			switch vv := vv.(type) {
+			case CostType:
+				cts[name] = vv
+			case map[string]interface{}:
+				var ct CostType
+				ct.CostMetric, _ = vv["cost-metric"].(string)
+				ct.CostMode, _ = vv["cost-mode"].(string)
+				ct.CostSource, _ = vv["cost-source"].(string)
+				ct.Description, _ = vv["description"].(string)
+				cts[name] = ct
+			}
+		}
+	}
+	return cts
+}
+
+// CheckDirectory checks the consistency between the information
+// resource directory dir and information resources rs, which are keyed
+// by URI. It reports cost map PIDs missing from the network map,
+// cost maps depending on another version of the network map, network
+// map PIDs with no cost rows, and cost types not defined in the
+// directory. The network map a cost map depends on is resolved
+// through the uses of the cost map listed in dir.
+func CheckDirectory(dir *Directory, rs map[string]Data) []*Inconsistency {
+	var incs []*Inconsistency
+	cts := dir.Meta.CostTypes()
+	for _, r := range dir.Resources {
+		for _, name := range costTypeNames(r.Capabilities) {
+			if _, ok := cts[name]; !ok {
+				incs = append(incs, &Inconsistency{Kind: UnknownCostType, URI: r.URI, Detail: name})
+			}
+		}
+	}
+	nms := make(map[string]*NetworkMap)
+	for uri, d := range rs {
+		if nm, ok := d.(*NetworkMap); ok {
+			nms[uri] = nm
+		}
+	}
+	for uri, d := range rs {
+		cm, ok := d.(*CostMap)
+		if !ok {
+			continue
+		}
+		if !knownCostType(cts, cm.CostType) {
+			incs = append(incs, &Inconsistency{Kind: UnknownCostType, URI: uri, Detail: cm.CostType.CostMode + " " + cm.CostType.CostMetric})
+		}
+		nm, ok := dependentNetworkMap(dir, rs, uri, nms, cm)
+		if nm == nil {
+			detail := cm.VersionTag
+			if ok {
+				detail = "uses network map not given"
+			}
+			incs = append(incs, &Inconsistency{Kind: MissingNetworkMap, URI: uri, Detail: detail})
+			continue
+		}
+		incs = append(incs, checkCostMap(uri, nm, cm)...)
+	}
+	sort.Sort(byURI(incs))
+	return incs
+}
+
+func knownCostType(cts map[string]CostType, ct CostType) bool {
+	for _, v := range cts {
+		if v.CostMode == ct.CostMode && v.CostMetric == ct.CostMetric {
+			return true
+		}
+	}
+	return false
+}
+
+// dependentNetworkMap returns the network map that the cost map cm
+// at uri depends on. When the directory lists uses of the cost map, it
+// returns the network map among them, and reports true. Otherwise it
+// returns the network map of the same version tag, or the only
+// network map.
+func dependentNetworkMap(dir *Directory, rs map[string]Data, uri string, nms map[string]*NetworkMap, cm *CostMap) (*NetworkMap, bool) {
+	ids := make(map[string]string)
+	for _, r := range dir.Resources {
+		if r.ID != "" {
+			ids[r.ID] = r.URI
+		}
+	}
+	for _, r := range dir.Resources {
+		if r.URI != uri || len(r.Uses) == 0 {
+			continue
+		}
+		for _, id := range r.Uses {
+			if nm, ok := rs[ids[id]].(*NetworkMap); ok {
+				return nm, true
+			}
+		}
+		return nil, true
+	}
+	for _, nm := range nms {
+		if nm.VersionTag == cm.VersionTag {
+			return nm, false
+		}
+	}
+	if len(nms) == 1 {
+		for _, nm := range nms {
+			return nm, false
+		}
+	}
+	return nil, false
+}
+
+func checkCostMap(uri string, nm *NetworkMap, cm *CostMap) []*Inconsistency {
+	var incs []*Inconsistency
+	if nm.VersionTag != cm.VersionTag {
+		incs = append(incs, &Inconsistency{Kind: VersionTagMismatch, URI: uri, Detail: fmt.Sprintf("%q depends on %q", cm.VersionTag, nm.VersionTag)})
+	}
+	unknown := make(map[string]bool)
+	for src, dcs := range cm.Map {
+		if _, ok := nm.Map[src]; !ok {
+			unknown[src] = true
+		}
+		for dst := range dcs {
+			if _, ok := nm.Map[dst]; !ok {
+				unknown[dst] = true
+			}
+		}
+	}
+	for pid := range unknown {
+		incs = append(incs, &Inconsistency{Kind: UnknownPID, URI: uri, PID: pid})
+	}
+	for pid := range nm.Map {
+		if _, ok := cm.Map[pid]; !ok {
+			incs = append(incs, &Inconsistency{Kind: MissingCostRow, URI: uri, PID: pid})
+		}
+	}
+	return incs
+}
@@ -0,0 +1,89 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func decodeTestdata(t *testing.T, name string, v interface{}) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		t.Fatalf("json.Decoder.Decode failed: %v", err)
+	}
+}
+
+func TestCheckDirectory(t *testing.T) {
+	var dir Directory
+	decodeTestdata(t, "testdata/directory.js", &dir)
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	decodeTestdata(t, "testdata/networkmap.js", nm)
+	cm := NewResource("costmap").Data.(*CostMap)
+	decodeTestdata(t, "testdata/costmap.js", cm)
+	rs := map[string]Data{
+		"http://alto.example.com/networkmap":              nm,
+		"http://alto.example.com/costmap/num/routingcost": cm,
+	}
+	if incs := CheckDirectory(&dir, rs); len(incs) != 0 {
+		t.Fatalf("got %v; expected none", incs)
+	}
+
+	ep, err := ParseEndpoint("ipv4", "203.0.113.0/24")
+	if err != nil {
+		t.Fatalf("ParseEndpoint failed: %v", err)
+	}
+	nm.Set("pid4", ep)
+	cm.Map["pid5"] = DstCosts{"pid1": 1}
+	cm.VersionTag = "1266506140"
+	cm.CostType.CostMetric = "priv:latency"
+	dir.Resources[1].Capabilities.(*CostMapCapabilities).CostTypeNames = []string{"num-latency"}
+	incs := CheckDirectory(&dir, rs)
+	kinds := map[InconsistencyKind]int{
+		UnknownPID:         1,
+		VersionTagMismatch: 1,
+		MissingCostRow:     1,
+		UnknownCostType:    2,
+	}
+	for _, inc := range incs {
+		kinds[inc.Kind]--
+	}
+	for k, n := range kinds {
+		if n != 0 {
+			t.Fatalf("%v: got %v", k, incs)
+		}
+	}
+}
+
+func TestCheckDirectoryUses(t *testing.T) {
+	nm1 := &NetworkMap{VersionTag: "1", Map: map[string]EndpointAddrGroup{"pid1": {}}}
+	nm2 := &NetworkMap{VersionTag: "2", Map: map[string]EndpointAddrGroup{"pid1": {}}}
+	cm := &CostMap{CostType: CostType{CostMetric: "routingcost", CostMode: CostModeNumerical}, VersionTag: "3", Map: map[string]DstCosts{"pid1": {"pid1": 0}}}
+	dir := &Directory{
+		Meta: Meta{"cost-types": map[string]CostType{"num-routing": cm.CostType}},
+		Resources: []DirectoryResource{
+			{ID: "nm1", URI: "/nm1", MediaType: MediaTypeNetworkMap},
+			{ID: "nm2", URI: "/nm2", MediaType: MediaTypeNetworkMap},
+			{ID: "cm", URI: "/cm", MediaType: MediaTypeCostMap, Uses: []string{"nm2"}},
+		},
+	}
+	for _, tt := range []struct {
+		rs   map[string]Data
+		kind InconsistencyKind
+	}{
+		{map[string]Data{"/nm1": nm1, "/nm2": nm2, "/cm": cm}, VersionTagMismatch},
+		{map[string]Data{"/nm1": nm1, "/cm": cm}, MissingNetworkMap},
+	} {
+		incs := CheckDirectory(dir, tt.rs)
+		if len(incs) != 1 || incs[0].Kind != tt.kind {
+			t.Fatalf("got %v; expected %v", incs, tt.kind)
+		}
+	}
+}
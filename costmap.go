@@ -40,10 +40,21 @@ func (cm *CostMap) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &raw); err != nil {
 		return err
 	}
-	for key, v := range raw.(map[string]interface{}) {
+	if raw == nil {
+		return nil
+	}
+	m, err := objectOf("cost map", raw)
+	if err != nil {
+		return err
+	}
+	for key, v := range m {
 		switch key {
 		case "cost-type":
-			for key, vv := range v.(map[string]interface{}) {
+			ct, err := objectOf(key, v)
+			if err != nil {
+				return err
+			}
+			for key, vv := range ct {
 				switch key {
 				case "cost-metric":
 					if v, ok := vv.(string); ok {
@@ -53,6 +64,10 @@ func (cm *CostMap) UnmarshalJSON(b []byte) error {
 					if v, ok := vv.(string); ok {
 						cm.CostType.CostMode = v
 					}
+				case "cost-source":
+					if v, ok := vv.(string); ok {
+						cm.CostType.CostSource = v
+					}
 				case "description":
 					if v, ok := vv.(string); ok {
 						cm.CostType.Description = v
@@ -64,8 +79,12 @@ func (cm *CostMap) UnmarshalJSON(b []byte) error {
 				cm.VersionTag = v
 			}
 		case "map":
+			cmd, err := objectOf(key, v)
+			if err != nil {
+				return err
+			}
 			cm.Map = make(map[string]DstCosts)
-			for pid, vv := range v.(map[string]interface{}) {
+			for pid, vv := range cmd {
 				switch vv := vv.(type) {
 				case map[string]interface{}:
 					dcs := make(DstCosts)
@@ -92,10 +111,23 @@ func (cm *CostMap) resourceType() string {
 // provider-defined identifier (PID).
 type DstCosts map[string]float64
 
-// A CostType represents a combination of cost type and cost mode.
+const (
+	CostModeNumerical = "numerical" // costs are numerical values
+	CostModeOrdinal   = "ordinal"   // costs are ordinal rankings
+)
+
+const (
+	CostSourceNominal    = "nominal"    // costs are typical values configured or specified by design
+	CostSourceSLA        = "sla"        // costs are bounds promised by service level agreements
+	CostSourceEstimation = "estimation" // costs are estimated from measurements
+)
+
+// A CostType represents a combination of cost type and cost mode. The
+// cost source is described in RFC 9439.
 type CostType struct {
 	CostMetric  string `json:"cost-metric"`
 	CostMode    string `json:"cost-mode"`
+	CostSource  string `json:"cost-source,omitempty"`
 	Description string `json:"description,omitempty"`
 }
 
@@ -110,9 +142,57 @@ type ReqFilteredCostMap struct {
 	} `json:"pids,omitempty"`
 }
 
+// A CostMapCapabilities represents a capabilities for the cost map.
+type CostMapCapabilities struct {
+	CostTypeNames []string                   `json:"cost-type-names"`
+	Extra         map[string]json.RawMessage `json:"-"` // members not modeled above
+}
+
+// MarshalJSON implements the MarshalJSON method of json.Marshaler
+// interface.
+func (caps *CostMapCapabilities) MarshalJSON() ([]byte, error) {
+	type plain CostMapCapabilities
+	return marshalCapabilities((*plain)(caps), caps.Extra)
+}
+
+// UnmarshalJSON implements the UnmarshalJSON method of
+// json.Unmarshaler interface.
+func (caps *CostMapCapabilities) UnmarshalJSON(b []byte) error {
+	type plain CostMapCapabilities
+	extra, err := unmarshalCapabilities(b, (*plain)(caps))
+	caps.Extra = extra
+	return err
+}
+
+func (caps *CostMapCapabilities) capabilitiesType() string {
+	return "costmap"
+}
+
 // A FilteredCostMapCapabilities represents a capabilities for the
-// filtered cost map.
+// filtered cost map. It also represents a capabilities for the
+// endpoint cost service.
 type FilteredCostMapCapabilities struct {
-	CostTypeNames   []string `json:"cost-type-names"`
-	CostConstraints bool     `json:"cost-constraints"`
+	CostTypeNames   []string                   `json:"cost-type-names"`
+	CostConstraints bool                       `json:"cost-constraints"`
+	Extra           map[string]json.RawMessage `json:"-"` // members not modeled above
+}
+
+// MarshalJSON implements the MarshalJSON method of json.Marshaler
+// interface.
+func (caps *FilteredCostMapCapabilities) MarshalJSON() ([]byte, error) {
+	type plain FilteredCostMapCapabilities
+	return marshalCapabilities((*plain)(caps), caps.Extra)
+}
+
+// UnmarshalJSON implements the UnmarshalJSON method of
+// json.Unmarshaler interface.
+func (caps *FilteredCostMapCapabilities) UnmarshalJSON(b []byte) error {
+	type plain FilteredCostMapCapabilities
+	extra, err := unmarshalCapabilities(b, (*plain)(caps))
+	caps.Extra = extra
+	return err
+}
+
+func (caps *FilteredCostMapCapabilities) capabilitiesType() string {
+	return "filteredcostmap"
 }
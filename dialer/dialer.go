@@ -0,0 +1,241 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package dialer implements a network dialer that prefers the
+// cheapest resolved address in terms of the Application-Layer Traffic
+// Optimization (ALTO) costs.
+//
+// The DialContext method of Dialer can be used as the DialContext
+// field of http.Transport:
+//
+//	d := &dialer.Dialer{Ranker: rank.NewRanker(src, rank.MinCost{})}
+//	tr := &http.Transport{DialContext: d.DialContext}
+package dialer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/rank"
+)
+
+var errNoAddress = errors.New("no suitable address")
+
+// A Resolver represents a host name resolver. The net.Resolver
+// implements this interface.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// A Dialer represents a dialer that resolves a host name, ranks the
+// resolved addresses with an ALTO cost source, and dials them in
+// ranked order. Like Happy Eyeballs, an attempt to the next address
+// starts when the previous attempt fails or takes longer than
+// FallbackDelay, and the first established connection wins. When
+// ranking fails, the addresses are dialed in resolver order.
+type Dialer struct {
+	Dialer        net.Dialer    // dialer for each attempt
+	Resolver      Resolver      // nil means net.DefaultResolver
+	Ranker        *rank.Ranker  // ranker of resolved addresses
+	Source        alto.Endpoint // source endpoint for ranking; nil means the local address of Dialer, or the one chosen by routing
+	FallbackDelay time.Duration // zero means 300 milliseconds
+}
+
+// DialContext connects to address on the named network, which must
+// be "tcp", "tcp4" or "tcp6".
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := d.resolve(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	return d.race(ctx, network, d.rank(ctx, addrs, port), port)
+}
+
+func (d *Dialer) resolve(ctx context.Context, network, host string) ([]net.IPAddr, error) {
+	if ia, ok := parseIPAddr(host); ok {
+		return []net.IPAddr{ia}, nil
+	}
+	var r Resolver = net.DefaultResolver
+	if d.Resolver != nil {
+		r = d.Resolver
+	}
+	ias, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []net.IPAddr
+	for _, ia := range ias {
+		ipv4 := ia.IP.To4() != nil
+		if network == "tcp4" && !ipv4 || network == "tcp6" && ipv4 {
+			continue
+		}
+		addrs = append(addrs, ia)
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: errNoAddress.Error(), Name: host}
+	}
+	return addrs, nil
+}
+
+// parseIPAddr parses host as an IP address literal with an optional
+// IPv6 zone.
+func parseIPAddr(host string) (net.IPAddr, bool) {
+	var zone string
+	if i := strings.LastIndex(host, "%"); i > 0 {
+		host, zone = host[:i], host[i+1:]
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || zone != "" && ip.To4() != nil {
+		return net.IPAddr{}, false
+	}
+	return net.IPAddr{IP: ip, Zone: zone}, true
+}
+
+// rank returns addrs in ranked order. It returns addrs as resolved
+// when ranking is not possible, because a failure of the cost source
+// must not make a reachable host undialable.
+func (d *Dialer) rank(ctx context.Context, addrs []net.IPAddr, port string) []net.IPAddr {
+	if len(addrs) < 2 || d.Ranker == nil {
+		return addrs
+	}
+	cands := make([]alto.Endpoint, 0, len(addrs))
+	byEndpoint := make(map[string][]net.IPAddr)
+	for _, ia := range addrs {
+		ep, err := alto.ParseEndpoint(network(ia.IP), ia.IP.String())
+		if err != nil {
+			return addrs
+		}
+		cands = append(cands, ep)
+		byEndpoint[ep.TypedString()] = append(byEndpoint[ep.TypedString()], ia)
+	}
+	src := d.Source
+	if src == nil {
+		ip, err := d.localAddr(ctx, addrs[0], port)
+		if err != nil {
+			return addrs
+		}
+		if src, err = alto.ParseEndpoint(network(ip), ip.String()); err != nil {
+			return addrs
+		}
+	}
+	cs, err := d.Ranker.Rank(ctx, src, cands)
+	if err != nil {
+		return addrs
+	}
+	ranked := make([]net.IPAddr, 0, len(cs))
+	for _, c := range cs {
+		k := c.Endpoint.TypedString()
+		if ias := byEndpoint[k]; len(ias) > 0 {
+			ranked = append(ranked, ias[0])
+			byEndpoint[k] = ias[1:]
+		}
+	}
+	return ranked
+}
+
+func network(ip net.IP) string {
+	if ip.To4() != nil {
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// localAddr returns the local address for reaching ia. It is the IP
+// address of the LocalAddr field of d.Dialer when set, and otherwise
+// the one that routing chooses. No packet is sent.
+func (d *Dialer) localAddr(ctx context.Context, ia net.IPAddr, port string) (net.IP, error) {
+	switch a := d.Dialer.LocalAddr.(type) {
+	case *net.TCPAddr:
+		if a != nil && a.IP != nil && !a.IP.IsUnspecified() {
+			return a.IP, nil
+		}
+	case *net.IPAddr:
+		if a != nil && a.IP != nil && !a.IP.IsUnspecified() {
+			return a.IP, nil
+		}
+	}
+	var nd net.Dialer
+	c, err := nd.DialContext(ctx, "udp", net.JoinHostPort(ia.String(), port))
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return c.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+type dialResult struct {
+	c   net.Conn
+	err error
+}
+
+func (d *Dialer) race(ctx context.Context, network string, addrs []net.IPAddr, port string) (net.Conn, error) {
+	delay := d.FallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, len(addrs))
+	dial := func(ia net.IPAddr) {
+		c, err := d.Dialer.DialContext(ctx, network, net.JoinHostPort(ia.String(), port))
+		results <- dialResult{c: c, err: err}
+	}
+	next, pending := 0, 0
+	var firstErr error
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if next < len(addrs) {
+				go dial(addrs[next])
+				next++
+				pending++
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				cancel()
+				go drain(results, pending)
+				return r.c, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(0)
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		case <-ctx.Done():
+			go drain(results, pending)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// drain closes connections established by the n attempts that lost
+// the race.
+func drain(results <-chan dialResult, n int) {
+	for ; n > 0; n-- {
+		if r := <-results; r.c != nil {
+			r.c.Close()
+		}
+	}
+}
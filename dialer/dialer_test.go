@@ -0,0 +1,146 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package dialer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/rank"
+)
+
+type resolverFunc func(ctx context.Context, host string) ([]net.IPAddr, error)
+
+func (f resolverFunc) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return f(ctx, host)
+}
+
+const dialerNetworkMap = `{
+	"map-vtag": "1",
+	"map": {
+		"near": {"ipv4": ["127.0.0.1/32", "127.0.0.3/32"]},
+		"far": {"ipv4": ["127.0.0.2/32"]},
+		"closed": {"ipv4": ["127.0.0.4/32"]}
+	}
+}`
+
+const dialerCostMap = `{
+	"cost-type": {"cost-mode": "numerical", "cost-metric": "routingcost"},
+	"map-vtag": "1",
+	"map": {
+		"near": {"closed": 1, "near": 2, "far": 10}
+	}
+}`
+
+func newTestRanker(t *testing.T) *rank.Ranker {
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	if err := json.Unmarshal([]byte(dialerNetworkMap), nm); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	cm := alto.NewResource("costmap").Data.(*alto.CostMap)
+	if err := json.Unmarshal([]byte(dialerCostMap), cm); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	return rank.NewRanker(&rank.MapSource{NetworkMap: nm, CostMap: cm}, rank.MinCost{})
+}
+
+func TestDialer(t *testing.T) {
+	ln1, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	defer ln1.Close()
+	_, port, _ := net.SplitHostPort(ln1.Addr().String())
+	ln2, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port))
+	if err != nil {
+		t.Skipf("net.Listen failed: %v", err)
+	}
+	defer ln2.Close()
+	for _, ln := range []net.Listener{ln1, ln2} {
+		go func(ln net.Listener) {
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				c.Close()
+			}
+		}(ln)
+	}
+
+	src, err := alto.ParseEndpoint("ipv4", "127.0.0.3")
+	if err != nil {
+		t.Fatalf("alto.ParseEndpoint failed: %v", err)
+	}
+	d := &Dialer{
+		Resolver: resolverFunc(func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP("127.0.0.2")}, {IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.4")}}, nil
+		}),
+		Ranker:        newTestRanker(t),
+		Source:        src,
+		FallbackDelay: time.Second,
+	}
+	// 127.0.0.4 is the cheapest but refuses the connection, and
+	// 127.0.0.1 is cheaper than 127.0.0.2.
+	c, err := d.DialContext(context.Background(), "tcp", net.JoinHostPort("mirror.example.com", port))
+	if err != nil {
+		t.Fatalf("Dialer.DialContext failed: %v", err)
+	}
+	defer c.Close()
+	if ip := c.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("got %v; expected %v", ip, "127.0.0.1")
+	}
+
+	if _, err := d.DialContext(context.Background(), "tcp6", net.JoinHostPort("mirror.example.com", port)); err == nil {
+		t.Fatalf("Dialer.DialContext succeeded without IPv6 address")
+	}
+
+	// The cost source is down, so the addresses are dialed in
+	// resolver order.
+	d.Ranker = rank.NewRanker(costSourceFunc(func(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error) {
+		return nil, errors.New("service unavailable")
+	}), rank.MinCost{})
+	c, err = d.DialContext(context.Background(), "tcp", net.JoinHostPort("mirror.example.com", port))
+	if err != nil {
+		t.Fatalf("Dialer.DialContext failed: %v", err)
+	}
+	defer c.Close()
+	if ip := c.RemoteAddr().(*net.TCPAddr).IP; !ip.Equal(net.ParseIP("127.0.0.2")) {
+		t.Fatalf("got %v; expected %v", ip, "127.0.0.2")
+	}
+}
+
+type costSourceFunc func(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error)
+
+func (f costSourceFunc) EndpointCosts(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error) {
+	return f(ctx, src, dsts)
+}
+
+func TestDialerRank(t *testing.T) {
+	var got alto.Endpoint
+	d := &Dialer{
+		Dialer: net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.3")}},
+		Ranker: rank.NewRanker(costSourceFunc(func(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error) {
+			got = src
+			return map[string]float64{dsts[1].TypedString(): 1, dsts[0].TypedString(): 2}, nil
+		}), rank.MinCost{}),
+	}
+	addrs := []net.IPAddr{{IP: net.ParseIP("fe80::1"), Zone: "eth0"}, {IP: net.ParseIP("fe80::2"), Zone: "eth1"}}
+	ranked := d.rank(context.Background(), addrs, "80")
+	if got == nil || got.String() != "127.0.0.3" {
+		t.Fatalf("got source %v; expected %v", got, "127.0.0.3")
+	}
+	if len(ranked) != 2 || ranked[0].String() != "fe80::2%eth1" || ranked[1].String() != "fe80::1%eth0" {
+		t.Fatalf("got %v; expected %v", ranked, []string{"fe80::2%eth1", "fe80::1%eth0"})
+	}
+	if ia, ok := parseIPAddr("fe80::1%eth0"); !ok || ia.Zone != "eth0" {
+		t.Fatalf("got %v, %v; expected %v", ia, ok, "fe80::1%eth0")
+	}
+}
@@ -4,6 +4,11 @@
 
 package alto
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 const (
 	MediaTypeDirectory = "application/alto-directory+json" // media type for ALTO directory service
 )
@@ -16,8 +21,220 @@ type Directory struct {
 
 // A DirectoryResource represents a list of information resources.
 type DirectoryResource struct {
-	URI          string                 `json:"uri"`
-	MediaType    string                 `json:"media-type"`
-	Accepts      string                 `json:"accepts,omitempty"`
-	Capabilities map[string]interface{} `json:"capabilities,omitempty"`
+	ID           string       `json:"id,omitempty"`
+	URI          string       `json:"uri"`
+	MediaType    string       `json:"media-type"`
+	Accepts      string       `json:"accepts,omitempty"`
+	Capabilities Capabilities `json:"capabilities,omitempty"`
+	Uses         []string     `json:"uses,omitempty"`
+}
+
+// UnmarshalJSON implements the UnmarshalJSON method of
+// json.Unmarshaler interface. It decodes capabilities into the type
+// suited to the media type and accepted input parameters of the
+// information resource. Members that the type does not model are
+// kept, and encoded again along with the modeled ones.
+func (dr *DirectoryResource) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		ID           string          `json:"id"`
+		URI          string          `json:"uri"`
+		MediaType    string          `json:"media-type"`
+		Accepts      string          `json:"accepts"`
+		Capabilities json.RawMessage `json:"capabilities"`
+		Uses         []string        `json:"uses"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	dr.ID, dr.URI, dr.MediaType, dr.Accepts, dr.Uses = raw.ID, raw.URI, raw.MediaType, raw.Accepts, raw.Uses
+	dr.Capabilities = nil
+	if len(raw.Capabilities) == 0 || string(raw.Capabilities) == "null" {
+		return nil
+	}
+	caps := NewCapabilities(raw.MediaType, raw.Accepts)
+	if err := json.Unmarshal(raw.Capabilities, caps); err != nil {
+		return err
+	}
+	dr.Capabilities = caps
+	return nil
+}
+
+// CostTypeNames returns the cost type names listed in the
+// capabilities of dr.
+func (dr *DirectoryResource) CostTypeNames() []string {
+	return costTypeNames(dr.Capabilities)
+}
+
+// A Capabilities represents capabilities of an information resource.
+type Capabilities interface {
+	capabilitiesType() string
+}
+
+// NewCapabilities returns empty capabilities for the information
+// resource with media type typ and accepted input parameters with
+// media type accepts. Unknown combinations are represented by
+// RawCapabilities.
+func NewCapabilities(typ, accepts string) Capabilities {
+	switch typ {
+	case MediaTypeCostMap:
+		if accepts == MediaTypeCostMapFilter {
+			return &FilteredCostMapCapabilities{}
+		}
+		return &CostMapCapabilities{}
+	case MediaTypeEndpointCost:
+		return &FilteredCostMapCapabilities{}
+	case MediaTypeEndpointProp:
+		return &EndpointPropertyCapabilities{}
+	default:
+		return &RawCapabilities{}
+	}
+}
+
+// marshalCapabilities returns the JSON encoding of the typed
+// capabilities v merged with the members extra that v does not model.
+func marshalCapabilities(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	for name, v := range extra {
+		if _, ok := raw[name]; !ok {
+			raw[name] = v
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// unmarshalCapabilities decodes b into the typed capabilities v and
+// returns the members that v does not model.
+func unmarshalCapabilities(b []byte, v interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	known, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var modeled map[string]json.RawMessage
+	if err := json.Unmarshal(known, &modeled); err != nil {
+		return nil, err
+	}
+	for name := range modeled {
+		delete(raw, name)
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	return raw, nil
+}
+
+// A RawCapabilities represents capabilities of an information
+// resource that has no typed capabilities.
+type RawCapabilities map[string]interface{}
+
+func (caps *RawCapabilities) capabilitiesType() string {
+	return "raw"
+}
+
+// costTypeNames returns the cost type names listed in caps.
+func costTypeNames(caps Capabilities) []string {
+	switch caps := caps.(type) {
+	case *CostMapCapabilities:
+		return caps.CostTypeNames
+	case *FilteredCostMapCapabilities:
+		return caps.CostTypeNames
+	case *RawCapabilities:
+		var names []string
+		v, _ := (*caps)["cost-type-names"].([]interface{})
+		for _, name := range v {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// A DirectoryBuilder represents a builder of an information resource
+// directory.
+type DirectoryBuilder struct {
+	costTypes map[string]CostType
+	resources []DirectoryResource
+	ids       map[string]int // resource id to index of resources
+}
+
+// NewDirectoryBuilder returns a new directory builder.
+func NewDirectoryBuilder() *DirectoryBuilder {
+	return &DirectoryBuilder{costTypes: make(map[string]CostType), ids: make(map[string]int)}
+}
+
+// AddCostType registers the cost type ct with name.
+func (b *DirectoryBuilder) AddCostType(name string, ct CostType) error {
+	if _, ok := b.costTypes[name]; ok {
+		return fmt.Errorf("duplicate cost type %s", name)
+	}
+	b.costTypes[name] = ct
+	return nil
+}
+
+// AddResource registers the information resource dr. The resource
+// must have a unique ID.
+func (b *DirectoryBuilder) AddResource(dr DirectoryResource) error {
+	if dr.ID == "" {
+		return errMissingResourceID
+	}
+	if dr.URI == "" {
+		return errMissingURI
+	}
+	if _, ok := b.ids[dr.ID]; ok {
+		return fmt.Errorf("duplicate resource id %s", dr.ID)
+	}
+	b.ids[dr.ID] = len(b.resources)
+	b.resources = append(b.resources, dr)
+	return nil
+}
+
+// Build checks the consistency of the registered cost types and
+// information resources and returns a directory. Each resource must
+// use only registered resources, cost maps must use a network map,
+// and capabilities must refer only to registered cost types.
+func (b *DirectoryBuilder) Build() (*Directory, error) {
+	for _, dr := range b.resources {
+		for _, id := range dr.Uses {
+			i, ok := b.ids[id]
+			if !ok {
+				return nil, fmt.Errorf("resource %s uses unknown resource %s", dr.ID, id)
+			}
+			if dr.MediaType == MediaTypeCostMap && b.resources[i].MediaType != MediaTypeNetworkMap {
+				return nil, fmt.Errorf("cost map %s uses non-network map %s", dr.ID, id)
+			}
+		}
+		if dr.MediaType == MediaTypeCostMap && len(dr.Uses) == 0 {
+			return nil, fmt.Errorf("cost map %s uses no network map", dr.ID)
+		}
+		for _, name := range costTypeNames(dr.Capabilities) {
+			if _, ok := b.costTypes[name]; !ok {
+				return nil, fmt.Errorf("resource %s refers to unknown cost type %s", dr.ID, name)
+			}
+		}
+	}
+	dir := &Directory{Meta: make(Meta), Resources: make([]DirectoryResource, len(b.resources))}
+	if len(b.costTypes) > 0 {
+		cts := make(map[string]CostType)
+		for name, ct := range b.costTypes {
+			cts[name] = ct
+		}
+		dir.Meta["cost-types"] = cts
+	}
+	copy(dir.Resources, b.resources)
+	return dir, nil
 }
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -31,3 +32,109 @@ func TestDecodeEncodeDirectory(t *testing.T) {
 		t.Logf("%v", string(out.Bytes()))
 	}
 }
+
+func TestDirectoryCapabilities(t *testing.T) {
+	var d Directory
+	decodeTestdata(t, "testdata/directory.js", &d)
+	for _, r := range d.Resources {
+		switch r.MediaType {
+		case MediaTypeCostMap:
+			caps, ok := r.Capabilities.(*CostMapCapabilities)
+			if !ok || len(caps.CostTypeNames) != 1 {
+				t.Fatalf("got %#v; expected cost map capabilities", r.Capabilities)
+			}
+		case MediaTypeEndpointCost:
+			caps, ok := r.Capabilities.(*FilteredCostMapCapabilities)
+			if !ok || !caps.CostConstraints || len(caps.CostTypeNames) != 4 {
+				t.Fatalf("got %#v; expected filtered cost map capabilities", r.Capabilities)
+			}
+		case MediaTypeEndpointProp:
+			caps, ok := r.Capabilities.(*EndpointPropertyCapabilities)
+			if !ok || len(caps.PropTypes) != 1 || caps.PropTypes[0] != "pid" {
+				t.Fatalf("got %#v; expected endpoint property capabilities", r.Capabilities)
+			}
+		default:
+			if r.Capabilities != nil {
+				t.Fatalf("got %#v; expected none", r.Capabilities)
+			}
+		}
+	}
+}
+
+func TestDirectoryCapabilitiesExtra(t *testing.T) {
+	const in = `{"uri": "/costmap", "media-type": "application/alto-costmap+json", "capabilities": {"cost-type-names": ["num-routing"], "testable-cost-type-names": ["num-routing"], "x-vendor": {"a": 1}}}`
+	var dr DirectoryResource
+	if err := json.Unmarshal([]byte(in), &dr); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	caps, ok := dr.Capabilities.(*CostMapCapabilities)
+	if !ok || len(caps.CostTypeNames) != 1 || len(caps.Extra) != 2 {
+		t.Fatalf("got %#v; expected cost map capabilities with 2 extra members", dr.Capabilities)
+	}
+	b, err := json.Marshal(&dr)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var got, expected interface{}
+	json.Unmarshal(b, &got)
+	json.Unmarshal([]byte(in), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %s; expected %s", b, in)
+	}
+}
+
+func TestDirectoryBuilder(t *testing.T) {
+	b := NewDirectoryBuilder()
+	if err := b.AddCostType("num-routing", CostType{CostMetric: "routingcost", CostMode: CostModeNumerical}); err != nil {
+		t.Fatalf("DirectoryBuilder.AddCostType failed: %v", err)
+	}
+	if err := b.AddCostType("num-routing", CostType{}); err == nil {
+		t.Fatalf("DirectoryBuilder.AddCostType succeeded for duplicate cost type")
+	}
+	for _, dr := range []DirectoryResource{
+		{ID: "my-network-map", URI: "http://alto.example.com/networkmap", MediaType: MediaTypeNetworkMap},
+		{ID: "my-routingcost-map", URI: "http://alto.example.com/costmap/num/routingcost", MediaType: MediaTypeCostMap, Capabilities: &CostMapCapabilities{CostTypeNames: []string{"num-routing"}}, Uses: []string{"my-network-map"}},
+		{ID: "endpoint-property", URI: "http://alto.example.com/endpointprop/lookup", MediaType: MediaTypeEndpointProp, Accepts: MediaTypeEndpointPropParams, Capabilities: &EndpointPropertyCapabilities{PropTypes: []string{"pid"}}, Uses: []string{"my-network-map"}},
+	} {
+		if err := b.AddResource(dr); err != nil {
+			t.Fatalf("DirectoryBuilder.AddResource failed: %v", err)
+		}
+	}
+	if err := b.AddResource(DirectoryResource{ID: "my-network-map", URI: "http://alto.example.com/networkmap"}); err == nil {
+		t.Fatalf("DirectoryBuilder.AddResource succeeded for duplicate id")
+	}
+	d, err := b.Build()
+	if err != nil {
+		t.Fatalf("DirectoryBuilder.Build failed: %v", err)
+	}
+	b1, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var dd Directory
+	if err := json.Unmarshal(b1, &dd); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(dd.Resources, d.Resources) {
+		t.Fatalf("got %v; expected %v", dd.Resources, d.Resources)
+	}
+	if cts := dd.Meta.CostTypes(); len(cts) != 1 || cts["num-routing"].CostMetric != "routingcost" {
+		t.Fatalf("got %v; expected num-routing", cts)
+	}
+
+	for _, dr := range []DirectoryResource{
+		{ID: "bad-uses", URI: "http://alto.example.com/costmap/a", MediaType: MediaTypeCostMap, Uses: []string{"no-such-map"}},
+		{ID: "bad-dependency", URI: "http://alto.example.com/costmap/b", MediaType: MediaTypeCostMap, Uses: []string{"endpoint-property"}},
+		{ID: "bad-cost-type", URI: "http://alto.example.com/costmap/c", MediaType: MediaTypeCostMap, Capabilities: &CostMapCapabilities{CostTypeNames: []string{"num-hop"}}, Uses: []string{"my-network-map"}},
+	} {
+		b := NewDirectoryBuilder()
+		b.AddResource(DirectoryResource{ID: "my-network-map", URI: "http://alto.example.com/networkmap", MediaType: MediaTypeNetworkMap})
+		b.AddResource(DirectoryResource{ID: "endpoint-property", URI: "http://alto.example.com/endpointprop/lookup", MediaType: MediaTypeEndpointProp})
+		if err := b.AddResource(dr); err != nil {
+			t.Fatalf("DirectoryBuilder.AddResource failed: %v", err)
+		}
+		if _, err := b.Build(); err == nil {
+			t.Fatalf("DirectoryBuilder.Build succeeded for %v", dr.ID)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+var (
+	ipv4PrefixLens = []int{32, 24, 16, 8}
+	ipv6PrefixLens = []int{128, 64, 56, 48, 32}
+)
+
+// A CrossDomain represents a cross-domain ALTO server discovery
+// procedure, which finds the ALTO server responsible for an arbitrary
+// target address as described in RFC 8686. Results are cached per
+// address prefix.
+type CrossDomain struct {
+	Resolver Resolver      // nil means a DNSResolver with default settings
+	TTL      time.Duration // lifetime of cached results; zero means 1 hour
+
+	mu    sync.Mutex
+	cache map[string]crossDomainEntry // keyed by reverse DNS name of prefix
+}
+
+type crossDomainEntry struct {
+	uris    []string
+	expires time.Time
+}
+
+// Lookup returns URIs of information resource directories of the
+// ALTO server responsible for the IP endpoint ep, in order of
+// preference. It looks up the reverse DNS names of the address and of
+// its shorter prefixes, which are /24, /16 and /8 for IPv4 and /64,
+// /56, /48 and /32 for IPv6, and stops at the first name that yields
+// any URI.
+func (cd *CrossDomain) Lookup(ctx context.Context, ep alto.Endpoint) ([]string, error) {
+	ipep, ok := ep.(*alto.IPEndpoint)
+	if !ok || ipep == nil || ipep.IP == nil {
+		return nil, errInvalidAddr
+	}
+	ip, l := ipep.IP.Addr(), ipep.IP.Len()
+	pls := ipv6PrefixLens
+	if ipep.Network() == "ipv4" {
+		ip, pls = ip.To4(), ipv4PrefixLens
+	}
+	for _, pl := range pls {
+		if pl > l {
+			continue
+		}
+		name, err := reverseName(ip, pl)
+		if err != nil {
+			return nil, err
+		}
+		uris, err := cd.lookup(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(uris) > 0 {
+			return uris, nil
+		}
+	}
+	return nil, errNoIRD
+}
+
+func (cd *CrossDomain) lookup(ctx context.Context, name string) ([]string, error) {
+	now := time.Now()
+	cd.mu.Lock()
+	e, ok := cd.cache[name]
+	cd.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.uris, nil
+	}
+	r := cd.Resolver
+	if r == nil {
+		r = &DNSResolver{}
+	}
+	uris, err := lookupUNAPTR(ctx, r, name, ServiceHTTPS)
+	if err != nil {
+		return nil, err
+	}
+	ttl := cd.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	cd.mu.Lock()
+	if cd.cache == nil {
+		cd.cache = make(map[string]crossDomainEntry)
+	}
+	cd.cache[name] = crossDomainEntry{uris: uris, expires: now.Add(ttl)}
+	cd.mu.Unlock()
+	return uris, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+var crossDomainRecords = map[string][]*NAPTR{
+	"2.0.192.in-addr.arpa": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto.example.net/ird!"},
+	},
+	"100.51.198.in-addr.arpa": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "SIP+D2U", Regexp: "!.*!sip:info@example.net!"},
+	},
+	"8.b.d.0.1.0.0.2.ip6.arpa": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto.example.org/ird!"},
+	},
+}
+
+var crossDomainTests = []struct {
+	net, addr string
+	uri       string
+	queries   []string
+}{
+	{
+		"ipv4", "192.0.2.1", "https://alto.example.net/ird",
+		[]string{"1.2.0.192.in-addr.arpa", "2.0.192.in-addr.arpa"},
+	},
+	{
+		"ipv4", "192.0.2.2", "https://alto.example.net/ird",
+		[]string{"2.2.0.192.in-addr.arpa"},
+	},
+	{
+		"ipv4", "192.0.2.0/24", "https://alto.example.net/ird",
+		nil,
+	},
+	{
+		"ipv4", "198.51.100.1", "",
+		[]string{"1.100.51.198.in-addr.arpa", "100.51.198.in-addr.arpa", "51.198.in-addr.arpa", "198.in-addr.arpa"},
+	},
+	{
+		"ipv6", "2001:db8::1", "https://alto.example.org/ird",
+		[]string{
+			"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"8.b.d.0.1.0.0.2.ip6.arpa",
+		},
+	},
+}
+
+func TestCrossDomainLookup(t *testing.T) {
+	ts := newTestServer(t, crossDomainRecords, nil)
+	defer ts.Close()
+	cd := &CrossDomain{Resolver: &DNSResolver{Server: ts.Addr()}}
+	var n int
+	for _, tt := range crossDomainTests {
+		ep, err := alto.ParseEndpoint(tt.net, tt.addr)
+		if err != nil {
+			t.Fatalf("alto.ParseEndpoint failed: %v", err)
+		}
+		uris, err := cd.Lookup(context.Background(), ep)
+		if tt.uri == "" {
+			if err == nil {
+				t.Fatalf("CrossDomain.Lookup(%v) succeeded", ep)
+			}
+		} else if err != nil || len(uris) != 1 || uris[0] != tt.uri {
+			t.Fatalf("CrossDomain.Lookup(%v) = %v, %v; expected %v", ep, uris, err, tt.uri)
+		}
+		qs := ts.Queries()
+		if !reflect.DeepEqual(qs[n:], tt.queries) && !(len(qs) == n && len(tt.queries) == 0) {
+			t.Fatalf("%v: got %v; expected %v", ep, qs[n:], tt.queries)
+		}
+		n = len(qs)
+	}
+}
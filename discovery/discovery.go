@@ -0,0 +1,203 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package discovery implements the Application-Layer Traffic
+// Optimization (ALTO) server discovery as described in RFC 7286, and
+// the cross-domain server discovery as described in RFC 8686.
+//
+// The discovery procedure resolves a domain name to URIs of ALTO
+// information resource directories with the Straightforward-NAPTR
+// (U-NAPTR) resolution described in RFC 4848.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ServiceHTTPS is the U-NAPTR application service tag for the ALTO
+// protocol over HTTPS.
+const ServiceHTTPS = "ALTO:https"
+
+const maxNonTerminals = 8
+
+var (
+	errNoIRD       = errors.New("no information resource directory")
+	errInvalidAddr = errors.New("invalid address")
+)
+
+// Discover returns candidate URIs of information resource directories
+// for the configured domain name domain and the client's address ip,
+// in order of preference. Either domain or ip may be empty. The
+// candidates for domain precede those for the reverse DNS name of ip,
+// and a failed lookup for domain falls back to the lookup for ip. It
+// returns the first lookup error only when no candidate is found.
+// A nil r means a DNSResolver with default settings.
+func Discover(ctx context.Context, r Resolver, domain string, ip net.IP) ([]string, error) {
+	var uris []string
+	var firstErr error
+	if domain != "" {
+		us, err := LookupDomain(ctx, r, domain)
+		if err != nil {
+			firstErr = err
+		}
+		uris = append(uris, us...)
+	}
+	if ip != nil {
+		us, err := LookupAddr(ctx, r, ip)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		uris = append(uris, us...)
+	}
+	if len(uris) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, errNoIRD
+	}
+	return uris, nil
+}
+
+// LookupDomain returns URIs of information resource directories for
+// domain name domain, in order of preference.
+func LookupDomain(ctx context.Context, r Resolver, domain string) ([]string, error) {
+	if r == nil {
+		r = &DNSResolver{}
+	}
+	return lookupUNAPTR(ctx, r, domain, ServiceHTTPS)
+}
+
+// LookupAddr returns URIs of information resource directories for the
+// reverse DNS name of IP address ip, in order of preference.
+func LookupAddr(ctx context.Context, r Resolver, ip net.IP) ([]string, error) {
+	name, err := reverseName(ip, 0)
+	if err != nil {
+		return nil, err
+	}
+	return LookupDomain(ctx, r, name)
+}
+
+// reverseName returns the reverse DNS name of ip. When bits is
+// positive, only the leading bits of the address are used. It must
+// be a multiple of 8 for IPv4 and of 4 for IPv6.
+func reverseName(ip net.IP, bits int) (string, error) {
+	var labels []string
+	var suffix string
+	if ipv4 := ip.To4(); ipv4 != nil {
+		for _, b := range ipv4 {
+			labels = append(labels, fmt.Sprintf("%d", b))
+		}
+		suffix = "in-addr.arpa"
+		if bits > 0 {
+			labels = labels[:bits/8]
+		}
+	} else if ipv6 := ip.To16(); ipv6 != nil {
+		for _, b := range ipv6 {
+			labels = append(labels, fmt.Sprintf("%x", b>>4), fmt.Sprintf("%x", b&0xf))
+		}
+		suffix = "ip6.arpa"
+		if bits > 0 {
+			labels = labels[:bits/4]
+		}
+	} else {
+		return "", errInvalidAddr
+	}
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+	return strings.Join(append(labels, suffix), "."), nil
+}
+
+type byOrder []*NAPTR
+
+func (s byOrder) Len() int      { return len(s) }
+func (s byOrder) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byOrder) Less(i, j int) bool {
+	if s[i].Order != s[j].Order {
+		return s[i].Order < s[j].Order
+	}
+	return s[i].Preference < s[j].Preference
+}
+
+// lookupUNAPTR runs the U-NAPTR resolution for service on the
+// application unique string aus. Non-terminal records are followed
+// to the domain name in their replacement fields. For each domain
+// name, only the records of the lowest order that contains
+// applicable records are used, as described in RFC 3403.
+func lookupUNAPTR(ctx context.Context, r Resolver, aus, service string) ([]string, error) {
+	var uris []string
+	visited := make(map[string]bool)
+	names := []string{aus}
+	for i := 0; i < len(names) && i < maxNonTerminals; i++ {
+		name := strings.ToLower(strings.TrimSuffix(names[i], "."))
+		if visited[name] {
+			continue
+		}
+		visited[name] = true
+		rrs, err := r.LookupNAPTR(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		sort.Stable(byOrder(rrs))
+		matched := false
+		var order uint16
+		for _, rr := range rrs {
+			if matched && rr.Order != order {
+				break
+			}
+			if !strings.EqualFold(rr.Service, service) {
+				continue
+			}
+			switch strings.ToLower(rr.Flags) {
+			case "u":
+				if uri, err := substitute(rr.Regexp, aus); err == nil {
+					uris = append(uris, uri)
+					matched, order = true, rr.Order
+				}
+			case "":
+				if rr.Replacement != "" {
+					names = append(names, rr.Replacement)
+					matched, order = true, rr.Order
+				}
+			}
+		}
+	}
+	return uris, nil
+}
+
+// substitute applies the substitution expression re of a terminal
+// NAPTR resource record to aus and returns the resulting URI.
+func substitute(re, aus string) (string, error) {
+	if len(re) < 3 {
+		return "", fmt.Errorf("invalid regexp %q", re)
+	}
+	delim := re[:1]
+	fs := strings.Split(re[1:], delim)
+	if len(fs) != 3 {
+		return "", fmt.Errorf("invalid regexp %q", re)
+	}
+	ere, err := regexp.Compile(fs[0])
+	if err != nil {
+		return "", err
+	}
+	if !ere.MatchString(aus) {
+		return "", fmt.Errorf("regexp %q does not match %q", re, aus)
+	}
+	uri := strings.Replace(fs[1], `\\`, `\`, -1)
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if !u.IsAbs() {
+		return "", fmt.Errorf("non-absolute uri %q", uri)
+	}
+	return uri, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A testServer represents a local DNS server answering NAPTR queries.
+type testServer struct {
+	records  map[string][]*NAPTR
+	truncate map[string]bool // names answered with truncation over UDP
+
+	mu      sync.Mutex
+	queries []string
+
+	uc net.PacketConn
+	tl net.Listener
+}
+
+func newTestServer(t *testing.T, records map[string][]*NAPTR, truncate map[string]bool) *testServer {
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen failed: %v", err)
+	}
+	uc, err := net.ListenPacket("udp", tl.Addr().String())
+	if err != nil {
+		tl.Close()
+		t.Skipf("net.ListenPacket failed: %v", err)
+	}
+	ts := &testServer{records: records, truncate: truncate, uc: uc, tl: tl}
+	go ts.serveUDP()
+	go ts.serveTCP()
+	return ts
+}
+
+func (ts *testServer) Addr() string {
+	return ts.tl.Addr().String()
+}
+
+func (ts *testServer) Close() {
+	ts.uc.Close()
+	ts.tl.Close()
+}
+
+func (ts *testServer) Queries() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.queries...)
+}
+
+func (ts *testServer) serveUDP() {
+	b := make([]byte, 512)
+	for {
+		n, addr, err := ts.uc.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		if resp := ts.respond(b[:n], true); resp != nil {
+			ts.uc.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (ts *testServer) serveTCP() {
+	for {
+		c, err := ts.tl.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			var l [2]byte
+			if _, err := io.ReadFull(c, l[:]); err != nil {
+				return
+			}
+			b := make([]byte, binary.BigEndian.Uint16(l[:]))
+			if _, err := io.ReadFull(c, b); err != nil {
+				return
+			}
+			resp := ts.respond(b, false)
+			binary.BigEndian.PutUint16(l[:], uint16(len(resp)))
+			c.Write(append(l[:], resp...))
+		}()
+	}
+}
+
+func (ts *testServer) respond(q []byte, udp bool) []byte {
+	name, off, err := unpackName(q, 12)
+	if err != nil {
+		return nil
+	}
+	name = strings.ToLower(name)
+	ts.mu.Lock()
+	ts.queries = append(ts.queries, name)
+	ts.mu.Unlock()
+	b := make([]byte, 12, 512)
+	copy(b, q[:2])
+	flags := uint16(0x8180)
+	rrs, ok := ts.records[name]
+	switch {
+	case !ok:
+		flags |= rcodeNameError
+	case udp && ts.truncate[name]:
+		flags |= 0x0200
+		rrs = nil
+	}
+	binary.BigEndian.PutUint16(b[2:], flags)
+	binary.BigEndian.PutUint16(b[4:], 1)
+	binary.BigEndian.PutUint16(b[6:], uint16(len(rrs)))
+	b = append(b, q[12:off+4]...)
+	for _, rr := range rrs {
+		b = append(b, 0xc0, 12, 0, typeNAPTR, 0, classINET, 0, 0, 0x0e, 0x10, 0, 0)
+		rdoff := len(b)
+		b = append(b, byte(rr.Order>>8), byte(rr.Order), byte(rr.Preference>>8), byte(rr.Preference))
+		for _, s := range []string{rr.Flags, rr.Service, rr.Regexp} {
+			b = append(b, byte(len(s)))
+			b = append(b, s...)
+		}
+		b, _ = packName(b, rr.Replacement)
+		binary.BigEndian.PutUint16(b[rdoff-2:], uint16(len(b)-rdoff))
+	}
+	return b
+}
+
+var discoveryRecords = map[string][]*NAPTR{
+	"example.net": {
+		{Order: 100, Preference: 20, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto2.example.net/ird!"},
+		{Order: 100, Preference: 10, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto1.example.net/ird!"},
+		{Order: 100, Preference: 10, Flags: "u", Service: "SIP+D2U", Regexp: "!.*!sip:info@example.net!"},
+		{Order: 200, Preference: 10, Flags: "", Service: "ALTO:https", Replacement: "alto.example.org"},
+	},
+	"example.info": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "SIP+D2U", Regexp: "!.*!sip:info@example.info!"},
+		{Order: 200, Preference: 10, Flags: "", Service: "ALTO:https", Replacement: "alto.example.org"},
+	},
+	"alto.example.org": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto.example.org/ird!"},
+		{Order: 200, Preference: 10, Flags: "", Service: "ALTO:https", Replacement: "example.net"},
+	},
+	"1.2.0.192.in-addr.arpa": {
+		{Order: 100, Preference: 10, Flags: "U", Service: "ALTO:https", Regexp: "!.*!https://alto.example.com/ird!"},
+	},
+	"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa": {
+		{Order: 100, Preference: 10, Flags: "u", Service: "ALTO:https", Regexp: "!.*!https://alto6.example.com/ird!"},
+	},
+}
+
+func TestDiscover(t *testing.T) {
+	ts := newTestServer(t, discoveryRecords, map[string]bool{"alto.example.org": true})
+	defer ts.Close()
+	r := &DNSResolver{Server: ts.Addr()}
+
+	uris, err := Discover(context.Background(), r, "example.net", net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	expected := []string{
+		"https://alto1.example.net/ird",
+		"https://alto2.example.net/ird",
+		"https://alto.example.com/ird",
+	}
+	if !reflect.DeepEqual(uris, expected) {
+		t.Fatalf("got %v; expected %v", uris, expected)
+	}
+
+	uris, err = LookupDomain(context.Background(), r, "example.info")
+	if err != nil {
+		t.Fatalf("LookupDomain failed: %v", err)
+	}
+	if len(uris) != 1 || uris[0] != "https://alto.example.org/ird" {
+		t.Fatalf("got %v; expected %v", uris, "https://alto.example.org/ird")
+	}
+
+	uris, err = LookupAddr(context.Background(), r, net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatalf("LookupAddr failed: %v", err)
+	}
+	if len(uris) != 1 || uris[0] != "https://alto6.example.com/ird" {
+		t.Fatalf("got %v; expected %v", uris, "https://alto6.example.com/ird")
+	}
+
+	if _, err := Discover(context.Background(), r, "example.com", nil); err == nil {
+		t.Fatalf("Discover succeeded for unknown domain")
+	}
+}
+
+func TestDiscoverResolver(t *testing.T) {
+	r := resolverFunc(func(ctx context.Context, name string) ([]*NAPTR, error) {
+		return discoveryRecords[name], nil
+	})
+	uris, err := LookupDomain(context.Background(), r, "alto.example.org")
+	if err != nil {
+		t.Fatalf("LookupDomain failed: %v", err)
+	}
+	if len(uris) != 1 || uris[0] != "https://alto.example.org/ird" {
+		t.Fatalf("got %v", uris)
+	}
+
+	r = resolverFunc(func(ctx context.Context, name string) ([]*NAPTR, error) {
+		if name == "example.net" {
+			return nil, errors.New("server failure")
+		}
+		return discoveryRecords[name], nil
+	})
+	uris, err = Discover(context.Background(), r, "example.net", net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(uris) != 1 || uris[0] != "https://alto.example.com/ird" {
+		t.Fatalf("got %v; expected %v", uris, "https://alto.example.com/ird")
+	}
+	if _, err := Discover(context.Background(), r, "example.net", nil); err == nil {
+		t.Fatal("Discover succeeded for failed lookup")
+	}
+}
+
+type resolverFunc func(ctx context.Context, name string) ([]*NAPTR, error)
+
+func (f resolverFunc) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	return f(ctx, name)
+}
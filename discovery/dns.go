@@ -0,0 +1,299 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	errMessageTooShort = errors.New("dns message too short")
+	errInvalidName     = errors.New("invalid domain name")
+	errIDMismatch      = errors.New("dns message id mismatch")
+)
+
+const (
+	typeNAPTR = 35
+	classINET = 1
+
+	rcodeNameError = 3
+)
+
+// A NAPTR represents a Naming Authority Pointer (NAPTR) resource
+// record as described in RFC 3403.
+type NAPTR struct {
+	Order       uint16
+	Preference  uint16
+	Flags       string
+	Service     string
+	Regexp      string
+	Replacement string
+}
+
+// A Resolver represents a resolver for NAPTR resource records.
+type Resolver interface {
+	// LookupNAPTR returns the NAPTR resource records for name.
+	// It returns no records and no error when name does not
+	// exist.
+	LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error)
+}
+
+// A DNSResolver represents a stub resolver that queries a DNS server
+// for NAPTR resource records over UDP, falling back to TCP on
+// truncation.
+type DNSResolver struct {
+	Server  string        // address of DNS server; "host:port", empty means the first nameserver in /etc/resolv.conf
+	Timeout time.Duration // zero means 5 seconds
+}
+
+// LookupNAPTR implements the LookupNAPTR method of Resolver
+// interface.
+func (r *DNSResolver) LookupNAPTR(ctx context.Context, name string) ([]*NAPTR, error) {
+	server := r.Server
+	if server == "" {
+		server = systemServer()
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	q, err := packQuery(id, name, typeNAPTR)
+	if err != nil {
+		return nil, err
+	}
+	b, err := exchange(ctx, "udp", server, q)
+	if err != nil {
+		return nil, err
+	}
+	rrs, truncated, err := parseNAPTRResponse(b, id)
+	if err != nil || !truncated {
+		return rrs, err
+	}
+	if b, err = exchange(ctx, "tcp", server, q); err != nil {
+		return nil, err
+	}
+	rrs, _, err = parseNAPTRResponse(b, id)
+	return rrs, err
+}
+
+func systemServer() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fs := strings.Fields(s.Text())
+		if len(fs) >= 2 && fs[0] == "nameserver" {
+			return net.JoinHostPort(fs[1], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
+
+func exchange(ctx context.Context, network, server string, q []byte) ([]byte, error) {
+	var d net.Dialer
+	c, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+	}
+	if network == "udp" {
+		if _, err := c.Write(q); err != nil {
+			return nil, err
+		}
+		b := make([]byte, 65535)
+		n, err := c.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+	b := make([]byte, 2+len(q))
+	binary.BigEndian.PutUint16(b, uint16(len(q)))
+	copy(b[2:], q)
+	if _, err := c.Write(b); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(c, b[:2]); err != nil {
+		return nil, err
+	}
+	b = make([]byte, binary.BigEndian.Uint16(b[:2]))
+	if _, err := io.ReadFull(c, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// packName appends the wire format of domain name name to b.
+func packName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, l := range strings.Split(name, ".") {
+			if len(l) == 0 || len(l) > 63 {
+				return nil, errInvalidName
+			}
+			b = append(b, byte(len(l)))
+			b = append(b, l...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+func packQuery(id uint16, name string, typ uint16) ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(b[4:6], 1)
+	b, err := packName(b, name)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, byte(typ>>8), byte(typ), 0, classINET)
+	return b, nil
+}
+
+// unpackName returns the domain name at off in the message b and the
+// offset following it.
+func unpackName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end, jumps := -1, 0
+	for {
+		if off >= len(b) {
+			return "", 0, errMessageTooShort
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			off++
+			if end < 0 {
+				end = off
+			}
+			return strings.Join(labels, "."), end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errMessageTooShort
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, errInvalidName
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		case l&0xc0 != 0:
+			return "", 0, errInvalidName
+		default:
+			if off+1+l > len(b) {
+				return "", 0, errMessageTooShort
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+func unpackString(b []byte, off int) (string, int, error) {
+	if off >= len(b) || off+1+int(b[off]) > len(b) {
+		return "", 0, errMessageTooShort
+	}
+	l := int(b[off])
+	return string(b[off+1 : off+1+l]), off + 1 + l, nil
+}
+
+// parseNAPTRResponse returns the NAPTR resource records in the answer
+// section of the response message b to the query with id.
+func parseNAPTRResponse(b []byte, id uint16) ([]*NAPTR, bool, error) {
+	if len(b) < 12 {
+		return nil, false, errMessageTooShort
+	}
+	if binary.BigEndian.Uint16(b[0:2]) != id {
+		return nil, false, errIDMismatch
+	}
+	flags := binary.BigEndian.Uint16(b[2:4])
+	if flags&0x0200 != 0 {
+		return nil, true, nil
+	}
+	switch rcode := flags & 0x000f; rcode {
+	case 0:
+	case rcodeNameError:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("dns response code %d", rcode)
+	}
+	qdcount, ancount := int(binary.BigEndian.Uint16(b[4:6])), int(binary.BigEndian.Uint16(b[6:8]))
+	off := 12
+	for i := 0; i < qdcount; i++ {
+		_, n, err := unpackName(b, off)
+		if err != nil {
+			return nil, false, err
+		}
+		off = n + 4
+	}
+	var rrs []*NAPTR
+	for i := 0; i < ancount; i++ {
+		_, n, err := unpackName(b, off)
+		if err != nil {
+			return nil, false, err
+		}
+		if n+10 > len(b) {
+			return nil, false, errMessageTooShort
+		}
+		typ := binary.BigEndian.Uint16(b[n:])
+		rdlen := int(binary.BigEndian.Uint16(b[n+8:]))
+		off = n + 10
+		if off+rdlen > len(b) {
+			return nil, false, errMessageTooShort
+		}
+		if typ == typeNAPTR {
+			rr, err := unpackNAPTR(b[:off+rdlen], off)
+			if err != nil {
+				return nil, false, err
+			}
+			rrs = append(rrs, rr)
+		}
+		off += rdlen
+	}
+	return rrs, false, nil
+}
+
+func unpackNAPTR(b []byte, off int) (*NAPTR, error) {
+	if off+4 > len(b) {
+		return nil, errMessageTooShort
+	}
+	rr := &NAPTR{Order: binary.BigEndian.Uint16(b[off:]), Preference: binary.BigEndian.Uint16(b[off+2:])}
+	off += 4
+	var err error
+	for _, s := range []*string{&rr.Flags, &rr.Service, &rr.Regexp} {
+		if *s, off, err = unpackString(b, off); err != nil {
+			return nil, err
+		}
+	}
+	if rr.Replacement, _, err = unpackName(b, off); err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
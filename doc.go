@@ -9,12 +9,8 @@
 //
 // Encoding at server side:
 //
-//	accepts := req.Header.Get("Accept")
-//	if accepts == "" {
-//		// error handling
-//	}
-//	if !strings.Contains(accepts, alto.MediaTypeDirectory) {
-//		// error handling
+//	if !alto.Negotiate(w, req, alto.MediaTypeDirectory, "") {
+//		return
 //	}
 //	w.Header().Set("Content-Type", alto.MediaTypeDirectory)
 //	var dir alto.Directory
@@ -39,6 +35,9 @@
 //	if resp.StatusCode != http.StatusOK {
 //		// error handling
 //	}
+//	if !alto.MatchMediaType(resp.Header.Get("Content-Type"), alto.MediaTypeNetworkMap) {
+//		// error handling
+//	}
 //	nmap := alto.NewResource("networkmap")
 //	if err := json.NewDecoder(resp.Body).Decode(nmap); err != nil {
 //		// error handling
@@ -7,6 +7,7 @@ package alto
 import (
 	"encoding/json"
 	"net"
+	"sort"
 	"strings"
 
 	"github.com/mikioh/ipaddr"
@@ -180,7 +181,14 @@ func (eag EndpointAddrGroup) UnmarshalJSON(b []byte) error {
 }
 
 func (eag EndpointAddrGroup) decode(raw interface{}) error {
-	for typ, v := range raw.(map[string]interface{}) {
+	if raw == nil {
+		return nil
+	}
+	m, err := objectOf("endpoint address group", raw)
+	if err != nil {
+		return err
+	}
+	for typ, v := range m {
 		switch v := v.(type) {
 		case []interface{}:
 			var eps []Endpoint
@@ -218,3 +226,69 @@ func (eag EndpointAddrGroup) toSlice(typ string) []Endpoint {
 	return rs
 
 }
+
+// Aggregate canonicalizes the endpoint address group eag in place.
+// It removes duplicate endpoints and IP address prefixes covered by
+// other prefixes, merges sibling prefixes into their supernet, and
+// sorts endpoints by address. Aggregation never changes whether an
+// address matches a prefix within eag, but it may change the result
+// of longest-prefix match against a network map when other PIDs have
+// prefixes between the removed prefix and the one covering it. Use
+// the Aggregate method of NetworkMap to aggregate a whole network map
+// without changing longest-prefix match.
+func (eag EndpointAddrGroup) Aggregate() {
+	for typ, eps := range eag {
+		switch typ {
+		case "ipv4", "ipv6":
+			eag[typ] = aggregateIPEndpoints(eps)
+		default:
+			eag[typ] = sortEndpoints(eps)
+		}
+	}
+}
+
+func aggregateIPEndpoints(eps []Endpoint) []Endpoint {
+	var ps, others []Endpoint
+	var pes []prefixEntry
+	for _, ep := range eps {
+		if p, ok := toIPPrefix(ep); ok {
+			pes = append(pes, prefixEntry{p: p})
+		} else {
+			others = append(others, ep)
+		}
+	}
+	sort.Sort(byPrefix(pes))
+	var stack []ipPrefix
+	for _, pe := range pes {
+		if len(stack) > 0 && stack[len(stack)-1].contains(pe.p) {
+			continue
+		}
+		stack = append(stack, pe.p)
+		for len(stack) > 1 && stack[len(stack)-2].sibling(stack[len(stack)-1]) {
+			p := stack[len(stack)-1].parent()
+			stack = append(stack[:len(stack)-2], p)
+		}
+	}
+	for _, p := range stack {
+		ps = append(ps, p.endpoint())
+	}
+	return append(ps, sortEndpoints(others)...)
+}
+
+type byString []Endpoint
+
+func (s byString) Len() int           { return len(s) }
+func (s byString) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byString) Less(i, j int) bool { return s[i].String() < s[j].String() }
+
+func sortEndpoints(eps []Endpoint) []Endpoint {
+	sort.Sort(byString(eps))
+	var rs []Endpoint
+	for i, ep := range eps {
+		if i > 0 && ep.String() == eps[i-1].String() {
+			continue
+		}
+		rs = append(rs, ep)
+	}
+	return rs
+}
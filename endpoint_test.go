@@ -94,3 +94,44 @@ func TestParseMACEndpoint(t *testing.T) {
 		}
 	}
 }
+
+var aggregateTests = []struct {
+	in  map[string][]string
+	out map[string][]string
+}{
+	{
+		map[string][]string{"ipv4": {"198.51.100.128/25", "192.0.2.0/24", "198.51.100.0/25"}},
+		map[string][]string{"ipv4": {"192.0.2.0/24", "198.51.100.0/24"}},
+	},
+	{
+		map[string][]string{"ipv4": {"192.0.2.0/26", "192.0.2.5", "192.0.2.64/26", "192.0.2.128/25", "192.0.2.0/26", "192.0.4.0/24"}},
+		map[string][]string{"ipv4": {"192.0.2.0/24", "192.0.4.0/24"}},
+	},
+	{
+		map[string][]string{"ipv6": {"2001:db8:0:2::/64", "2001:db8:0:1::/64", "2001:db8:0:3::/64", "2001:db8::/64", "2001:db8::1"}},
+		map[string][]string{"ipv6": {"2001:db8::/62"}},
+	},
+	{
+		map[string][]string{"mac-48": {"01:23:45:67:89:ac", "01:23:45:67:89:ab", "01:23:45:67:89:ac"}},
+		map[string][]string{"mac-48": {"01:23:45:67:89:ab", "01:23:45:67:89:ac"}},
+	},
+}
+
+func TestEndpointAddrGroupAggregate(t *testing.T) {
+	for _, tt := range aggregateTests {
+		eag := make(EndpointAddrGroup)
+		for typ, ss := range tt.in {
+			for _, s := range ss {
+				ep, err := ParseEndpoint(typ, s)
+				if err != nil {
+					t.Fatalf("ParseEndpoint(%q, %q) failed: %v", typ, s, err)
+				}
+				eag[typ] = append(eag[typ], ep)
+			}
+		}
+		eag.Aggregate()
+		if out := eag.encode(); !reflect.DeepEqual(out, tt.out) {
+			t.Fatalf("got %v; expected %v", out, tt.out)
+		}
+	}
+}
@@ -4,6 +4,8 @@
 
 package alto
 
+import "encoding/json"
+
 const (
 	MediaTypeEndpointCost       = "application/alto-endpointcost+json"       // media type for ALTO endpoint cost service
 	MediaTypeEndpointCostParams = "application/alto-endpointcostparams+json" // media type for ALTO endpoint cost service
@@ -20,11 +22,40 @@ type ReqEndpointCostMap struct {
 	} `json:"endpoints"`
 }
 
+// MarshalJSON implements the MarshalJSON method of json.Marshaler
+// interface. Endpoints are encoded as typed addresses.
+func (req *ReqEndpointCostMap) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{})
+	raw["cost-type"] = req.CostType
+	if len(req.Constraints) > 0 {
+		raw["constraints"] = req.Constraints
+	}
+	eps := make(map[string][]string)
+	if len(req.Endpoints.Srcs) > 0 {
+		eps["srcs"] = typedStrings(req.Endpoints.Srcs)
+	}
+	eps["dsts"] = typedStrings(req.Endpoints.Dsts)
+	raw["endpoints"] = eps
+	return json.Marshal(raw)
+}
+
+func typedStrings(eps []Endpoint) []string {
+	ss := make([]string, len(eps))
+	for i := range eps {
+		ss[i] = eps[i].TypedString()
+	}
+	return ss
+}
+
 // An EndpointCostMap reprensents a list of endpoint cost maps.
 type EndpointCostMap struct {
 	CostType CostType                    `json:"cost-type"`
 	Map      map[string]EndpointDstCosts `json:"map"`
 }
 
+func (ecm *EndpointCostMap) resourceType() string {
+	return "endpointcost"
+}
+
 // An EndpointDstCosts represents a set of endpoint cost maps.
 type EndpointDstCosts map[string]interface{}
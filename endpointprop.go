@@ -4,6 +4,8 @@
 
 package alto
 
+import "encoding/json"
+
 const (
 	MediaTypeEndpointProp       = "application/alto-endpointprop+json"       // media type for ALTO endpoint property service
 	MediaTypeEndpointPropParams = "application/alto-endpointpropparams+json" // media type for ALTO endpoint property service
@@ -16,10 +18,40 @@ type ReqEndpointProp struct {
 	Endpoints  []Endpoint `json:"endpoints"`
 }
 
+// MarshalJSON implements the MarshalJSON method of json.Marshaler
+// interface. Endpoints are encoded as typed addresses.
+func (req *ReqEndpointProp) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]interface{})
+	raw["properties"] = req.Properties
+	raw["endpoints"] = typedStrings(req.Endpoints)
+	return json.Marshal(raw)
+}
+
 // An EndpointPropertyCapabilities reprensents a capabilities of
 // endpoint property.
 type EndpointPropertyCapabilities struct {
-	PropTypes []string `json:"prop-types"`
+	PropTypes []string                   `json:"prop-types"`
+	Extra     map[string]json.RawMessage `json:"-"` // members not modeled above
+}
+
+// MarshalJSON implements the MarshalJSON method of json.Marshaler
+// interface.
+func (caps *EndpointPropertyCapabilities) MarshalJSON() ([]byte, error) {
+	type plain EndpointPropertyCapabilities
+	return marshalCapabilities((*plain)(caps), caps.Extra)
+}
+
+// UnmarshalJSON implements the UnmarshalJSON method of
+// json.Unmarshaler interface.
+func (caps *EndpointPropertyCapabilities) UnmarshalJSON(b []byte) error {
+	type plain EndpointPropertyCapabilities
+	extra, err := unmarshalCapabilities(b, (*plain)(caps))
+	caps.Extra = extra
+	return err
+}
+
+func (caps *EndpointPropertyCapabilities) capabilitiesType() string {
+	return "endpointprop"
 }
 
 // An EndpointProperty represents a list of endpoint properties.
@@ -28,5 +60,9 @@ type EndpointProperty struct {
 	Map        map[string]EndpointProps `json:"map"`
 }
 
+func (ep *EndpointProperty) resourceType() string {
+	return "endpointprop"
+}
+
 // An EndpointProps represents a set of endpoint properties.
 type EndpointProps map[string]interface{}
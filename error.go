@@ -4,12 +4,31 @@
 
 package alto
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	errUnknownAddress = errors.New("unknown address")
+	errUnknownAddress    = errors.New("unknown address")
+	errUnknownPID        = errors.New("unknown pid")
+	errDuplicatePID      = errors.New("duplicate pid")
+	errNoNetworkMap      = errors.New("no network map")
+	errStaleVersionTag   = errors.New("stale version tag")
+	errMissingResourceID = errors.New("missing resource id")
+	errMissingURI        = errors.New("missing uri")
 )
 
+// objectOf returns v as a JSON object. It reports an error naming
+// the member name when v is not an object.
+func objectOf(name string, v interface{}) (map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: expected object, got %T", name, v)
+	}
+	return m, nil
+}
+
 const (
 	MediaTypeError = "application/alto-error+json" // media type for ALTO error notification
 )
@@ -27,3 +46,7 @@ const (
 type Error struct {
 	Code string `json:"code"`
 }
+
+func (e *Error) Error() string {
+	return e.Code
+}
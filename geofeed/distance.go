@@ -0,0 +1,168 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package geofeed
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/mikioh/alto"
+)
+
+const earthRadius = 6371.0088 // mean radius of the Earth in kilometers
+
+// A Coordinate represents a geographic coordinate in degrees.
+type Coordinate struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func (c Coordinate) valid() bool {
+	return -90 <= c.Latitude && c.Latitude <= 90 && -180 <= c.Longitude && c.Longitude <= 180
+}
+
+// Distance returns the great-circle distance between c and d in
+// kilometers.
+func (c Coordinate) Distance(d Coordinate) float64 {
+	const rad = math.Pi / 180
+	lat1, lat2 := c.Latitude*rad, d.Latitude*rad
+	dlat, dlon := lat2-lat1, (d.Longitude-c.Longitude)*rad
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+// A Place represents a location named in geofeed entries.
+type Place struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// A Places represents a gazetteer that maps places to coordinates.
+type Places map[Place]Coordinate
+
+// Locate returns the coordinate of the most specific place that
+// matches the location of the entry e. It is suitable for
+// Importer.Locate.
+func (ps Places) Locate(e *Entry) (Coordinate, bool) {
+	for _, p := range []Place{
+		{Country: e.Country, Region: e.Region, City: e.City},
+		{Country: e.Country, Region: e.Region},
+		{Country: e.Country},
+	} {
+		if c, ok := ps[p]; ok {
+			return c, true
+		}
+	}
+	return Coordinate{}, false
+}
+
+// Coordinates returns the coordinates of the PIDs of the network map
+// nm taken from the properties props, such as the ones made by
+// Importer.Import. The coordinate of a PID is taken from its PID
+// properties, or from the endpoint properties of its prefixes when
+// all of them agree. PIDs that have no coordinates are omitted.
+func Coordinates(nm *alto.NetworkMap, props *alto.EndpointProperty) map[string]Coordinate {
+	cs := make(map[string]Coordinate)
+	if props == nil {
+		return cs
+	}
+	for pid, eag := range nm.Map {
+		if c, ok := coordinate(props.Map["pid:"+pid]); ok {
+			cs[pid] = c
+			continue
+		}
+		var found, conflict bool
+		var pc Coordinate
+		for _, typ := range []string{"ipv4", "ipv6"} {
+			for _, ep := range eag[typ] {
+				c, ok := coordinate(props.Map[ep.TypedString()])
+				if !ok {
+					continue
+				}
+				if found && c != pc {
+					conflict = true
+				}
+				pc, found = c, true
+			}
+		}
+		if found && !conflict {
+			cs[pid] = pc
+		}
+	}
+	return cs
+}
+
+func coordinate(props alto.EndpointProps) (Coordinate, bool) {
+	lat, ok1 := degrees(props[PropLatitude])
+	lon, ok2 := degrees(props[PropLongitude])
+	c := Coordinate{Latitude: lat, Longitude: lon}
+	return c, ok1 && ok2 && c.valid()
+}
+
+func degrees(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// A DistanceCost represents a generator of cost maps of the
+// great-circle distances between PIDs.
+type DistanceCost struct {
+	Metric string // cost metric; empty means "priv:geo-distance"
+
+	// Bands holds the upper bounds of distance bands in
+	// kilometers in ascending order. When it is not empty, the
+	// cost maps are in the ordinal cost mode, and the cost of a
+	// distance is 1 plus the number of bounds below it. Otherwise
+	// the cost maps are in the numerical cost mode, and the costs
+	// are distances in kilometers.
+	Bands []float64
+}
+
+// CostMap returns the cost map of the distances between the PIDs of
+// the network map nm located at the coordinates cs. The cost map
+// depends on nm. PIDs that have no coordinates have no costs.
+func (dc *DistanceCost) CostMap(nm *alto.NetworkMap, cs map[string]Coordinate) (*alto.CostMap, error) {
+	if !sort.Float64sAreSorted(dc.Bands) {
+		return nil, fmt.Errorf("distance bands not in ascending order")
+	}
+	cm := &alto.CostMap{
+		CostType:   alto.CostType{CostMetric: dc.Metric, CostMode: alto.CostModeNumerical},
+		VersionTag: nm.VersionTag,
+		Map:        make(map[string]alto.DstCosts),
+	}
+	if cm.CostType.CostMetric == "" {
+		cm.CostType.CostMetric = "priv:geo-distance"
+	}
+	if len(dc.Bands) > 0 {
+		cm.CostType.CostMode = alto.CostModeOrdinal
+	}
+	for src, sc := range cs {
+		if _, ok := nm.Map[src]; !ok {
+			continue
+		}
+		dcs := make(alto.DstCosts)
+		for dst, dstc := range cs {
+			if _, ok := nm.Map[dst]; !ok {
+				continue
+			}
+			d := sc.Distance(dstc)
+			if len(dc.Bands) > 0 {
+				d = float64(1 + sort.SearchFloat64s(dc.Bands, d))
+			}
+			dcs[dst] = d
+		}
+		cm.Map[src] = dcs
+	}
+	return cm, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package geofeed
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+var testPlaces = Places{
+	{Country: "US", Region: "US-CA", City: "San Francisco"}: {37.7749, -122.4194},
+	{Country: "US", Region: "US-CA", City: "Los Angeles"}:   {34.0522, -118.2437},
+	{Country: "US", Region: "US-WA"}:                        {47.6062, -122.3321},
+	{Country: "JP"}:                                         {35.6762, 139.6503},
+}
+
+func TestDistance(t *testing.T) {
+	for _, tt := range []struct {
+		c, d Coordinate
+		km   float64
+	}{
+		{Coordinate{0, 0}, Coordinate{0, 0}, 0},
+		{Coordinate{0, 0}, Coordinate{0, 180}, math.Pi * earthRadius},
+		{Coordinate{90, 0}, Coordinate{-90, 0}, math.Pi * earthRadius},
+		{testPlaces[Place{"US", "US-CA", "San Francisco"}], testPlaces[Place{"US", "US-CA", "Los Angeles"}], 559},
+	} {
+		if km := tt.c.Distance(tt.d); math.Abs(km-tt.km) > 1 {
+			t.Fatalf("%v-%v: got %v; expected %v", tt.c, tt.d, km, tt.km)
+		}
+	}
+}
+
+func TestDistanceCost(t *testing.T) {
+	for _, props := range []PropertyTarget{EndpointProps, PIDProps} {
+		imp := Importer{GroupBy: ByRegion, Properties: props, Locate: testPlaces.Locate}
+		nm, ep, err := imp.Import(strings.NewReader(testGeofeed))
+		if err != nil {
+			t.Fatalf("Importer.Import failed: %v", err)
+		}
+		cs := Coordinates(nm, ep)
+		if len(cs) != 2 {
+			t.Fatalf("got %v; expected coordinates of %v", cs, []string{"us-wa", "jp-13"})
+		}
+
+		dc := DistanceCost{}
+		cm, err := dc.CostMap(nm, cs)
+		if err != nil {
+			t.Fatalf("DistanceCost.CostMap failed: %v", err)
+		}
+		if cm.CostType != (alto.CostType{CostMetric: "priv:geo-distance", CostMode: alto.CostModeNumerical}) || cm.VersionTag != nm.VersionTag {
+			t.Fatalf("got %v, %v; expected %v", cm.CostType, cm.VersionTag, "priv:geo-distance")
+		}
+		if km := cm.Map["us-wa"]["jp-13"]; math.Abs(km-7696) > 10 {
+			t.Fatalf("got %v; expected %v", km, 7696)
+		}
+
+		dc = DistanceCost{Metric: "priv:distance-band", Bands: []float64{100, 1000, 5000}}
+		if cm, err = dc.CostMap(nm, cs); err != nil {
+			t.Fatalf("DistanceCost.CostMap failed: %v", err)
+		}
+		if err := cm.ValidateOrdinal(); err != nil {
+			t.Fatalf("alto.CostMap.ValidateOrdinal failed: %v", err)
+		}
+		m := map[string]alto.DstCosts{"us-wa": {"us-wa": 1, "jp-13": 4}, "jp-13": {"us-wa": 4, "jp-13": 1}}
+		if !reflect.DeepEqual(cm.Map, m) {
+			t.Fatalf("got %v; expected %v", cm.Map, m)
+		}
+	}
+
+	dc := DistanceCost{Bands: []float64{1000, 100}}
+	if _, err := dc.CostMap(alto.NewResource("networkmap").Data.(*alto.NetworkMap), nil); err == nil {
+		t.Fatal("got nil; expected an error for unordered bands")
+	}
+}
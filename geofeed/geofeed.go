@@ -0,0 +1,103 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package geofeed implements a reader and writer of self-published IP
+// geolocation feeds as described in RFC 8805, and a converter between
+// geofeeds and Application-Layer Traffic Optimization (ALTO) network
+// maps.
+package geofeed
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// An Entry represents a geofeed entry.
+type Entry struct {
+	Prefix     *net.IPNet
+	Country    string // ISO 3166-1 alpha-2 code, such as "US"
+	Region     string // ISO 3166-2 code, such as "US-CA"
+	City       string
+	PostalCode string // deprecated by RFC 8805
+}
+
+func (e *Entry) record() []string {
+	return []string{e.Prefix.String(), e.Country, e.Region, e.City, e.PostalCode}
+}
+
+// Parse parses a geofeed read from r. Empty lines and lines beginning
+// with "#" are ignored, and fields following the postal code are
+// ignored as described in RFC 8805. Country and region codes are
+// converted to upper case.
+func Parse(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var es []Entry
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		e, err := parseRecord(rec)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+func parseRecord(rec []string) (Entry, error) {
+	var fs [5]string
+	for i := range fs {
+		if i < len(rec) {
+			fs[i] = strings.TrimSpace(rec[i])
+		}
+	}
+	var e Entry
+	_, ipn, err := net.ParseCIDR(fs[0])
+	if err != nil {
+		return e, err
+	}
+	e.Prefix = ipn
+	e.Country, e.Region, e.City, e.PostalCode = strings.ToUpper(fs[1]), strings.ToUpper(fs[2]), fs[3], fs[4]
+	if e.Country != "" && !isAlpha2(e.Country) {
+		return e, fmt.Errorf("invalid country code %s", fs[1])
+	}
+	if e.Region != "" {
+		i := strings.IndexByte(e.Region, '-')
+		if i < 0 || !isAlpha2(e.Region[:i]) || len(e.Region) == i+1 || len(e.Region) > i+4 {
+			return e, fmt.Errorf("invalid region code %s", fs[2])
+		}
+		if e.Country != "" && e.Region[:i] != e.Country {
+			return e, fmt.Errorf("region code %s mismatches country code %s", fs[2], fs[1])
+		}
+	}
+	return e, nil
+}
+
+func isAlpha2(s string) bool {
+	return len(s) == 2 && 'A' <= s[0] && s[0] <= 'Z' && 'A' <= s[1] && s[1] <= 'Z'
+}
+
+// Write writes the geofeed entries es to w.
+func Write(w io.Writer, es []Entry) error {
+	cw := csv.NewWriter(w)
+	for i := range es {
+		if err := cw.Write(es[i].record()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,60 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package geofeed
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testGeofeed = `# prefix,country,region,city,postal code
+192.0.2.0/25,US,US-CA,San Francisco,
+192.0.2.128/25,us,us-ca,Los Angeles,
+198.51.100.0/24,US,US-WA,Seattle,,extension
+203.0.113.0/24,JP,JP-13,Chiyoda-ku,100-0001
+
+2001:db8::/32,JP,,,
+2001:db8:1::/48
+`
+
+func TestParse(t *testing.T) {
+	es, err := Parse(strings.NewReader(testGeofeed))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(es) != 6 {
+		t.Fatalf("got %v entries; expected 6", len(es))
+	}
+	if e := es[1]; e.Prefix.String() != "192.0.2.128/25" || e.Country != "US" || e.Region != "US-CA" || e.City != "Los Angeles" {
+		t.Fatalf("got %+v; expected %v", e, "192.0.2.128/25,US,US-CA,Los Angeles,")
+	}
+	if e := es[3]; e.PostalCode != "100-0001" {
+		t.Fatalf("got %v; expected %v", e.PostalCode, "100-0001")
+	}
+	var b bytes.Buffer
+	if err := Write(&b, es[:2]); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if s := "192.0.2.0/25,US,US-CA,San Francisco,\n192.0.2.128/25,US,US-CA,Los Angeles,\n"; b.String() != s {
+		t.Fatalf("got %q; expected %q", b.String(), s)
+	}
+}
+
+var parseErrorTests = []string{
+	"192.0.2.0,US,,,",
+	"192.0.2.0/24,USA,,,",
+	"192.0.2.0/24,US,CA,,",
+	"192.0.2.0/24,US,US-ABCD,,",
+	"192.0.2.0/24,US,JP-13,,",
+}
+
+func TestParseError(t *testing.T) {
+	for _, s := range parseErrorTests {
+		if _, err := Parse(strings.NewReader("# comment\n" + s + "\n")); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+			t.Fatalf("%s: got %v; expected an error at line 2", s, err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package geofeed
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/mikioh/alto"
+)
+
+// Property names of geo attributes. They are private property types
+// as described in RFC 7285.
+const (
+	PropCountry    = "priv:geo-country"
+	PropRegion     = "priv:geo-region"
+	PropCity       = "priv:geo-city"
+	PropPostalCode = "priv:geo-postal-code"
+	PropLatitude   = "priv:geo-latitude"  // in degrees
+	PropLongitude  = "priv:geo-longitude" // in degrees
+)
+
+// A GroupBy represents a criterion for grouping prefixes into
+// provider-defined identifiers (PIDs). PIDs consist of lower case
+// letters, digits and hyphens; other characters are replaced by
+// hyphens.
+type GroupBy int
+
+const (
+	ByCountry GroupBy = iota // PIDs are named after the country code, such as "us"
+	ByRegion                 // PIDs are named after the region code, such as "us-ca"
+	ByCity                   // PIDs are named after the region or country code followed by the city, such as "us-ca-san-francisco"
+)
+
+// A PropertyTarget represents the entities to which geo attributes are
+// attached.
+type PropertyTarget int
+
+const (
+	EndpointProps PropertyTarget = iota // properties are keyed by typed prefixes, such as "ipv4:192.0.2.0/24"
+	PIDProps                            // properties are keyed by "pid:" followed by the PID
+)
+
+// An Importer represents a converter from geofeeds to network maps.
+type Importer struct {
+	GroupBy    GroupBy
+	Properties PropertyTarget
+	Default    string // PID for prefixes lacking the grouped attribute; empty means to drop them
+
+	// Locate returns the coordinate of the location of the
+	// entry e, which is attached to the properties. Nil means to
+	// attach no coordinates.
+	Locate func(e *Entry) (Coordinate, bool)
+}
+
+// Import reads a geofeed from r and returns a network map and the
+// properties holding the geo attributes. The prefixes are kept as
+// listed, so that each of them maps back to a geofeed entry. The
+// version tag of the network map is derived from the content, and the
+// properties carry the same version tag. PID properties hold only the
+// attributes shared by all the entries of the PID.
+func (imp *Importer) Import(r io.Reader) (*alto.NetworkMap, *alto.EndpointProperty, error) {
+	es, err := Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	ep := &alto.EndpointProperty{Map: make(map[string]alto.EndpointProps)}
+	for i := range es {
+		e := &es[i]
+		pid := imp.pid(e)
+		if pid == "" {
+			continue
+		}
+		typ := "ipv4"
+		if e.Prefix.IP.To4() == nil {
+			typ = "ipv6"
+		}
+		addr, err := alto.ParseEndpoint(typ, e.Prefix.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		eag, ok := nm.Map[pid]
+		if !ok {
+			eag = make(alto.EndpointAddrGroup)
+			nm.Map[pid] = eag
+		}
+		eag[typ] = append(eag[typ], addr)
+		props := e.props()
+		if imp.Locate != nil {
+			if c, ok := imp.Locate(e); ok {
+				props[PropLatitude], props[PropLongitude] = c.Latitude, c.Longitude
+			}
+		}
+		if imp.Properties == EndpointProps {
+			ep.Map[addr.TypedString()] = props
+			continue
+		}
+		key := "pid:" + pid
+		shared, ok := ep.Map[key]
+		if !ok {
+			ep.Map[key] = props
+			continue
+		}
+		for name, v := range shared {
+			if props[name] != v {
+				delete(shared, name)
+			}
+		}
+	}
+	vtag, err := alto.ContentVersionTag(nm)
+	if err != nil {
+		return nil, nil, err
+	}
+	nm.VersionTag, ep.VersionTag = vtag, vtag
+	return nm, ep, nil
+}
+
+func (e *Entry) props() alto.EndpointProps {
+	props := make(alto.EndpointProps)
+	for name, v := range map[string]string{PropCountry: e.Country, PropRegion: e.Region, PropCity: e.City, PropPostalCode: e.PostalCode} {
+		if v != "" {
+			props[name] = v
+		}
+	}
+	return props
+}
+
+func (imp *Importer) pid(e *Entry) string {
+	var s string
+	switch imp.GroupBy {
+	case ByCountry:
+		s = e.Country
+	case ByRegion:
+		s = e.Region
+	case ByCity:
+		if e.City != "" {
+			if s = e.Region; s == "" {
+				s = e.Country
+			}
+			s += "-" + e.City
+		}
+	}
+	if s = pidName(s); s == "" {
+		return imp.Default
+	}
+	return s
+}
+
+// pidName converts s into a PID name of at most 64 characters.
+func pidName(s string) string {
+	var b bytes.Buffer
+	hyphen := false
+	for _, c := range strings.ToLower(s) {
+		if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	if b.Len() > 64 {
+		b.Truncate(64)
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// Export writes the IP prefixes of the network map nm to w as a
+// geofeed. The geo attributes of each prefix are taken from the
+// endpoint properties of the prefix in props, or the PID properties
+// when the prefix has none. Props may be nil. The entries are ordered
+// by address.
+func Export(w io.Writer, nm *alto.NetworkMap, props *alto.EndpointProperty) error {
+	var es []Entry
+	for pid, eag := range nm.Map {
+		for _, typ := range []string{"ipv4", "ipv6"} {
+			for _, addr := range eag[typ] {
+				_, ipn, err := net.ParseCIDR(addr.String())
+				if err != nil {
+					return err
+				}
+				e := Entry{Prefix: ipn}
+				if props != nil {
+					attrs, ok := props.Map[addr.TypedString()]
+					if !ok {
+						attrs = props.Map["pid:"+pid]
+					}
+					e.setProps(attrs)
+				}
+				es = append(es, e)
+			}
+		}
+	}
+	sort.Sort(byPrefix(es))
+	return Write(w, es)
+}
+
+func (e *Entry) setProps(props alto.EndpointProps) {
+	for name, p := range map[string]*string{PropCountry: &e.Country, PropRegion: &e.Region, PropCity: &e.City, PropPostalCode: &e.PostalCode} {
+		if v, ok := props[name].(string); ok {
+			*p = v
+		}
+	}
+}
+
+type byPrefix []Entry
+
+func (s byPrefix) Len() int      { return len(s) }
+func (s byPrefix) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byPrefix) Less(i, j int) bool {
+	a, b := s[i].Prefix, s[j].Prefix
+	if len(a.IP) != len(b.IP) {
+		return len(a.IP) < len(b.IP)
+	}
+	if n := bytes.Compare(a.IP, b.IP); n != 0 {
+		return n < 0
+	}
+	la, _ := a.Mask.Size()
+	lb, _ := b.Mask.Size()
+	return la < lb
+}
@@ -0,0 +1,96 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package geofeed
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+func TestImport(t *testing.T) {
+	for _, tt := range []struct {
+		imp  Importer
+		pids map[string]string
+	}{
+		{Importer{}, map[string]string{"us": "192.0.2.0/25 192.0.2.128/25 198.51.100.0/24", "jp": "203.0.113.0/24 2001:db8::/32"}},
+		{Importer{GroupBy: ByRegion, Default: "other"}, map[string]string{"us-ca": "192.0.2.0/25 192.0.2.128/25", "us-wa": "198.51.100.0/24", "jp-13": "203.0.113.0/24", "other": "2001:db8::/32 2001:db8:1::/48"}},
+		{Importer{GroupBy: ByCity}, map[string]string{"us-ca-san-francisco": "192.0.2.0/25", "us-ca-los-angeles": "192.0.2.128/25", "us-wa-seattle": "198.51.100.0/24", "jp-13-chiyoda-ku": "203.0.113.0/24"}},
+	} {
+		nm, ep, err := tt.imp.Import(strings.NewReader(testGeofeed))
+		if err != nil {
+			t.Fatalf("Importer.Import failed: %v", err)
+		}
+		if len(nm.Map) != len(tt.pids) {
+			t.Fatalf("got %v; expected %v", nm.Map, tt.pids)
+		}
+		for pid, s := range tt.pids {
+			var ss []string
+			for _, typ := range []string{"ipv4", "ipv6"} {
+				for _, ep := range nm.Endpoints(pid, typ) {
+					ss = append(ss, ep.String())
+				}
+			}
+			if strings.Join(ss, " ") != s {
+				t.Fatalf("%s: got %v; expected %v", pid, ss, s)
+			}
+		}
+		if vtag, _ := alto.ContentVersionTag(nm); nm.VersionTag != vtag || ep.VersionTag != vtag {
+			t.Fatalf("got %v, %v; expected %v", nm.VersionTag, ep.VersionTag, vtag)
+		}
+	}
+}
+
+func TestImportProperties(t *testing.T) {
+	imp := Importer{}
+	_, ep, err := imp.Import(strings.NewReader(testGeofeed))
+	if err != nil {
+		t.Fatalf("Importer.Import failed: %v", err)
+	}
+	props := alto.EndpointProps{PropCountry: "JP", PropRegion: "JP-13", PropCity: "Chiyoda-ku", PropPostalCode: "100-0001"}
+	if !reflect.DeepEqual(ep.Map["ipv4:203.0.113.0/24"], props) {
+		t.Fatalf("got %v; expected %v", ep.Map["ipv4:203.0.113.0/24"], props)
+	}
+
+	imp = Importer{Properties: PIDProps}
+	_, ep, err = imp.Import(strings.NewReader(testGeofeed))
+	if err != nil {
+		t.Fatalf("Importer.Import failed: %v", err)
+	}
+	for key, props := range map[string]alto.EndpointProps{
+		"pid:us": {PropCountry: "US"},
+		"pid:jp": {PropCountry: "JP"},
+	} {
+		if !reflect.DeepEqual(ep.Map[key], props) {
+			t.Fatalf("%s: got %v; expected %v", key, ep.Map[key], props)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	for _, imp := range []Importer{
+		{GroupBy: ByCity},
+		{GroupBy: ByCity, Properties: PIDProps},
+	} {
+		nm, ep, err := imp.Import(strings.NewReader(testGeofeed))
+		if err != nil {
+			t.Fatalf("Importer.Import failed: %v", err)
+		}
+		var b bytes.Buffer
+		if err := Export(&b, nm, ep); err != nil {
+			t.Fatalf("Export failed: %v", err)
+		}
+		s := "192.0.2.0/25,US,US-CA,San Francisco,\n" +
+			"192.0.2.128/25,US,US-CA,Los Angeles,\n" +
+			"198.51.100.0/24,US,US-WA,Seattle,\n" +
+			"203.0.113.0/24,JP,JP-13,Chiyoda-ku,100-0001\n"
+		if b.String() != s {
+			t.Fatalf("got %q; expected %q", b.String(), s)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+
+	"github.com/mikioh/ipaddr"
+)
+
+// An ipPrefix represents a masked IP address prefix. The length of ip
+// is net.IPv4len for IPv4 and net.IPv6len for IPv6.
+type ipPrefix struct {
+	ip  net.IP
+	len int
+}
+
+func toIPPrefix(ep Endpoint) (ipPrefix, bool) {
+	ipep, ok := ep.(*IPEndpoint)
+	if !ok || ipep == nil || ipep.IP == nil {
+		return ipPrefix{}, false
+	}
+	ip := ipep.IP.Addr()
+	switch ipep.Network() {
+	case "ipv4":
+		ip = ip.To4()
+	case "ipv6":
+		ip = ip.To16()
+	default:
+		return ipPrefix{}, false
+	}
+	if ip == nil {
+		return ipPrefix{}, false
+	}
+	return ipPrefix{ip: ip.Mask(net.CIDRMask(ipep.IP.Len(), len(ip)*8)), len: ipep.IP.Len()}, true
+}
+
+func (p ipPrefix) endpoint() Endpoint {
+	ip := make(net.IP, len(p.ip))
+	copy(ip, p.ip)
+	pfx, err := ipaddr.NewPrefix(ip, p.len)
+	if err != nil {
+		return nil
+	}
+	return &IPEndpoint{IP: pfx}
+}
+
+func (p ipPrefix) bits() int {
+	return len(p.ip) * 8
+}
+
+func (p ipPrefix) equal(q ipPrefix) bool {
+	return p.len == q.len && p.ip.Equal(q.ip) && len(p.ip) == len(q.ip)
+}
+
+// contains reports whether p covers q.
+func (p ipPrefix) contains(q ipPrefix) bool {
+	if len(p.ip) != len(q.ip) || p.len > q.len {
+		return false
+	}
+	return q.ip.Mask(net.CIDRMask(p.len, p.bits())).Equal(p.ip)
+}
+
+// parent returns the immediate supernet of p.
+func (p ipPrefix) parent() ipPrefix {
+	return ipPrefix{ip: p.ip.Mask(net.CIDRMask(p.len-1, p.bits())), len: p.len - 1}
+}
+
+// sibling reports whether p and q are the two halves of the same
+// supernet.
+func (p ipPrefix) sibling(q ipPrefix) bool {
+	return p.len > 0 && p.len == q.len && len(p.ip) == len(q.ip) && !p.ip.Equal(q.ip) && p.parent().equal(q.parent())
+}
+
+func (p ipPrefix) overlaps(q ipPrefix) bool {
+	return p.contains(q) || q.contains(p)
+}
+
+// compare orders prefixes by address family, first address and then
+// prefix length.
+func (p ipPrefix) compare(q ipPrefix) int {
+	if len(p.ip) != len(q.ip) {
+		return len(p.ip) - len(q.ip)
+	}
+	if n := bytes.Compare(p.ip, q.ip); n != 0 {
+		return n
+	}
+	return p.len - q.len
+}
+
+// first and last return the first and last addresses of p as
+// integers.
+func (p ipPrefix) first() *big.Int {
+	return new(big.Int).SetBytes(p.ip)
+}
+
+func (p ipPrefix) last() *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(1), uint(p.bits()-p.len))
+	n.Sub(n, big.NewInt(1))
+	return n.Add(n, p.first())
+}
+
+var ipv4MappedPrefix = ipPrefix{ip: net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 0, 0}, len: 96}
+
+// unmap returns the IPv4 prefix of the IPv4-mapped IPv6 prefix p.
+func (p ipPrefix) unmap() (ipPrefix, bool) {
+	if !ipv4MappedPrefix.contains(p) {
+		return ipPrefix{}, false
+	}
+	return ipPrefix{ip: p.ip[12:], len: p.len - 96}, true
+}
+
+// rangeToIPPrefixes returns a minimal list of prefixes covering the
+// address range from first to last inclusive.
+func rangeToIPPrefixes(first, last *big.Int, bits int) []ipPrefix {
+	var ps []ipPrefix
+	one := big.NewInt(1)
+	start := new(big.Int).Set(first)
+	for start.Cmp(last) <= 0 {
+		k := bits
+		if start.Sign() != 0 {
+			k = int(start.TrailingZeroBits())
+		}
+		for ; k > 0; k-- {
+			end := new(big.Int).Lsh(one, uint(k))
+			end.Add(end, start).Sub(end, one)
+			if end.Cmp(last) <= 0 {
+				break
+			}
+		}
+		ps = append(ps, ipPrefix{ip: net.IP(start.FillBytes(make([]byte, bits/8))), len: bits - k})
+		start.Add(start, new(big.Int).Lsh(one, uint(k)))
+	}
+	return ps
+}
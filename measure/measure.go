@@ -0,0 +1,249 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package measure implements an aggregator that builds
+// Application-Layer Traffic Optimization (ALTO) cost maps from
+// round-trip time and loss samples taken between vantage points.
+//
+// Samples are mapped to pairs of provider-defined identifiers (PIDs)
+// by looking up their source and destination addresses in a network
+// map, and summarized per pair of PIDs by a Statistic. The resulting
+// cost maps hold the delay-rt and lossrate cost metrics described in
+// RFC 9439.
+package measure
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+
+	"github.com/mikioh/alto"
+)
+
+// Cost metrics computed from samples.
+const (
+	DelayRT  = "delay-rt" // round-trip delay in microseconds
+	LossRate = "lossrate" // loss rate from 0 to 1
+)
+
+// A Statistic represents a statistic that summarizes samples.
+type Statistic interface {
+	// Summarize returns the summary of the values vs, which are in
+	// time order and not empty.
+	Summarize(vs []float64) float64
+}
+
+// A Median represents the median.
+type Median struct{}
+
+// Summarize implements the Summarize method of Statistic interface.
+func (Median) Summarize(vs []float64) float64 {
+	return Percentile{P: 50}.Summarize(vs)
+}
+
+// A Percentile represents a percentile. It interpolates linearly
+// between the closest ranks.
+type Percentile struct {
+	P float64 // percentile from 0 to 100, such as 95
+}
+
+// Summarize implements the Summarize method of Statistic interface.
+func (p Percentile) Summarize(vs []float64) float64 {
+	s := append([]float64(nil), vs...)
+	sort.Float64s(s)
+	x := math.Max(0, math.Min(100, p.P)) / 100 * float64(len(s)-1)
+	i := int(x)
+	if i == len(s)-1 {
+		return s[i]
+	}
+	return s[i] + (x-float64(i))*(s[i+1]-s[i])
+}
+
+// An EWMA represents the exponentially weighted moving average, which
+// weights recent values more.
+type EWMA struct {
+	Alpha float64 // smoothing factor from 0 to 1; zero means 0.125
+}
+
+// Summarize implements the Summarize method of Statistic interface.
+func (e EWMA) Summarize(vs []float64) float64 {
+	alpha := e.Alpha
+	if alpha == 0 {
+		alpha = 0.125
+	}
+	avg := vs[0]
+	for _, v := range vs[1:] {
+		avg += alpha * (v - avg)
+	}
+	return avg
+}
+
+// A FillPolicy represents a policy for filling the costs of pairs of
+// PIDs that have no samples.
+type FillPolicy int
+
+const (
+	FillNone    FillPolicy = iota // pairs without samples have no costs
+	FillReverse                   // pairs take the cost of the reverse direction, if any
+	FillMax                       // pairs take the largest cost of the cost map
+)
+
+// An Aggregator represents a builder of cost maps from samples.
+type Aggregator struct {
+	NetworkMap *alto.NetworkMap // network map to map addresses to PIDs
+	Statistic  Statistic        // nil means Median
+	MinSamples int              // minimum number of samples for a pair of PIDs to have costs; zero means 1
+	Fill       FillPolicy
+
+	// CostSource specifies the cost source of the cost maps:
+	// CostSourceEstimation for the costs observed, or
+	// CostSourceNominal or CostSourceSLA when the statistic is
+	// used to derive typical values or promised bounds. Empty
+	// means CostSourceEstimation.
+	CostSource string
+}
+
+// CostMaps returns the cost maps of the DelayRT and LossRate cost
+// metrics in the numerical cost mode built from the samples ss. The
+// cost maps are keyed by cost metric, and depend on the network map.
+// Samples of which source or destination addresses belong to no PID
+// are ignored.
+func (a *Aggregator) CostMaps(ss []Sample) (map[string]*alto.CostMap, error) {
+	src := a.CostSource
+	if src == "" {
+		src = alto.CostSourceEstimation
+	}
+	if src != alto.CostSourceNominal && src != alto.CostSourceSLA && src != alto.CostSourceEstimation {
+		return nil, fmt.Errorf("unknown cost source %s", src)
+	}
+	ss = append([]Sample(nil), ss...)
+	sort.Stable(byTime(ss))
+	rtts, losses := make(map[pidPair][]float64), make(map[pidPair][]float64)
+	for i := range ss {
+		pp, ok := a.pidPair(&ss[i])
+		if !ok {
+			continue
+		}
+		if !math.IsNaN(ss[i].RTT) {
+			rtts[pp] = append(rtts[pp], ss[i].RTT*1000)
+		}
+		if !math.IsNaN(ss[i].Loss) {
+			losses[pp] = append(losses[pp], ss[i].Loss)
+		}
+	}
+	cms := make(map[string]*alto.CostMap)
+	for metric, vs := range map[string]map[pidPair][]float64{DelayRT: rtts, LossRate: losses} {
+		cm := &alto.CostMap{
+			CostType:   alto.CostType{CostMetric: metric, CostMode: alto.CostModeNumerical, CostSource: src},
+			VersionTag: a.NetworkMap.VersionTag,
+			Map:        make(map[string]alto.DstCosts),
+		}
+		a.summarize(cm, vs)
+		a.fill(cm)
+		cms[metric] = cm
+	}
+	return cms, nil
+}
+
+type pidPair struct {
+	src, dst string
+}
+
+func (a *Aggregator) pidPair(s *Sample) (pidPair, bool) {
+	src, ok := a.lookup(s.Src)
+	if !ok {
+		return pidPair{}, false
+	}
+	dst, ok := a.lookup(s.Dst)
+	if !ok {
+		return pidPair{}, false
+	}
+	return pidPair{src: src, dst: dst}, true
+}
+
+func (a *Aggregator) lookup(ip net.IP) (string, bool) {
+	typ := "ipv4"
+	if ip.To4() == nil {
+		typ = "ipv6"
+	}
+	ep, err := alto.ParseEndpoint(typ, ip.String())
+	if err != nil {
+		return "", false
+	}
+	return a.NetworkMap.Lookup(ep)
+}
+
+// summarize sets the summaries of the values vs of pairs of PIDs in
+// the cost map cm.
+func (a *Aggregator) summarize(cm *alto.CostMap, vs map[pidPair][]float64) {
+	st := a.Statistic
+	if st == nil {
+		st = Median{}
+	}
+	min := a.MinSamples
+	if min < 1 {
+		min = 1
+	}
+	for pp, v := range vs {
+		if len(v) < min {
+			continue
+		}
+		dcs, ok := cm.Map[pp.src]
+		if !ok {
+			dcs = make(alto.DstCosts)
+			cm.Map[pp.src] = dcs
+		}
+		dcs[pp.dst] = st.Summarize(v)
+	}
+}
+
+// fill fills the costs of pairs of PIDs of the network map that the
+// cost map cm lacks.
+func (a *Aggregator) fill(cm *alto.CostMap) {
+	if a.Fill == FillNone {
+		return
+	}
+	max, found := 0.0, false
+	for _, dcs := range cm.Map {
+		for _, c := range dcs {
+			if !found || c > max {
+				max, found = c, true
+			}
+		}
+	}
+	if !found {
+		return
+	}
+	filled := make(map[pidPair]float64)
+	for src := range a.NetworkMap.Map {
+		for dst := range a.NetworkMap.Map {
+			if _, ok := cm.Map[src][dst]; ok {
+				continue
+			}
+			switch a.Fill {
+			case FillReverse:
+				if c, ok := cm.Map[dst][src]; ok {
+					filled[pidPair{src: src, dst: dst}] = c
+				}
+			case FillMax:
+				filled[pidPair{src: src, dst: dst}] = max
+			}
+		}
+	}
+	for pp, c := range filled {
+		dcs, ok := cm.Map[pp.src]
+		if !ok {
+			dcs = make(alto.DstCosts)
+			cm.Map[pp.src] = dcs
+		}
+		dcs[pp.dst] = c
+	}
+}
+
+type byTime []Sample
+
+func (s byTime) Len() int           { return len(s) }
+func (s byTime) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byTime) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
@@ -0,0 +1,122 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package measure
+
+import (
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+func TestStatistic(t *testing.T) {
+	vs := []float64{10, 50, 20, 40, 30}
+	for _, tt := range []struct {
+		st Statistic
+		v  float64
+	}{
+		{Median{}, 30},
+		{Percentile{P: 95}, 48},
+		{Percentile{P: 0}, 10},
+		{Percentile{P: 100}, 50},
+		{EWMA{Alpha: 0.5}, 31.25},
+		{EWMA{}, 10 + 0.125*40 + 0.125*(20-15) + 0.125*(40-15.625) + 0.125*(30-18.671875)},
+	} {
+		if v := tt.st.Summarize(vs); math.Abs(v-tt.v) > 1e-9 {
+			t.Fatalf("%#v: got %v; expected %v", tt.st, v, tt.v)
+		}
+	}
+	if v := (Median{}).Summarize([]float64{7}); v != 7 {
+		t.Fatalf("got %v; expected %v", v, 7)
+	}
+}
+
+func testNetworkMap() *alto.NetworkMap {
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	for pid, prefix := range map[string]string{"pid1": "192.0.2.0/24", "pid2": "198.51.100.0/24", "pid3": "203.0.113.0/24"} {
+		ep, _ := alto.ParseEndpoint("ipv4", prefix)
+		nm.Map[pid] = alto.EndpointAddrGroup{"ipv4": []alto.Endpoint{ep}}
+	}
+	nm.VersionTag = "1266506139"
+	return nm
+}
+
+func testSamples() []Sample {
+	t0 := time.Date(2013, 6, 1, 0, 0, 0, 0, time.UTC)
+	var ss []Sample
+	for i, rtt := range []float64{30, 10, 20, 40} {
+		ss = append(ss, Sample{Time: t0.Add(time.Duration(3-i) * time.Minute), Src: net.ParseIP("192.0.2.1"), Dst: net.ParseIP("198.51.100.1"), RTT: rtt, Loss: math.NaN()})
+	}
+	ss = append(ss,
+		Sample{Time: t0, Src: net.ParseIP("192.0.2.2"), Dst: net.ParseIP("198.51.100.2"), RTT: math.NaN(), Loss: 0.1},
+		Sample{Time: t0, Src: net.ParseIP("192.0.2.2"), Dst: net.ParseIP("192.0.2.1"), RTT: 1, Loss: 0},
+		Sample{Time: t0, Src: net.ParseIP("198.51.100.2"), Dst: net.ParseIP("2001:db8::1"), RTT: 5, Loss: 0},
+	)
+	return ss
+}
+
+func TestAggregator(t *testing.T) {
+	nm := testNetworkMap()
+	for _, tt := range []struct {
+		agg          Aggregator
+		rtts, losses map[string]alto.DstCosts
+	}{
+		{
+			Aggregator{},
+			map[string]alto.DstCosts{"pid1": {"pid1": 1000, "pid2": 25000}},
+			map[string]alto.DstCosts{"pid1": {"pid1": 0, "pid2": 0.1}},
+		},
+		{
+			Aggregator{Statistic: EWMA{Alpha: 0.5}, MinSamples: 2},
+			map[string]alto.DstCosts{"pid1": {"pid2": 25000}},
+			map[string]alto.DstCosts{},
+		},
+		{
+			Aggregator{Fill: FillReverse},
+			map[string]alto.DstCosts{"pid1": {"pid1": 1000, "pid2": 25000}, "pid2": {"pid1": 25000}},
+			map[string]alto.DstCosts{"pid1": {"pid1": 0, "pid2": 0.1}, "pid2": {"pid1": 0.1}},
+		},
+		{
+			Aggregator{Statistic: Percentile{P: 100}, Fill: FillMax},
+			map[string]alto.DstCosts{
+				"pid1": {"pid1": 1000, "pid2": 40000, "pid3": 40000},
+				"pid2": {"pid1": 40000, "pid2": 40000, "pid3": 40000},
+				"pid3": {"pid1": 40000, "pid2": 40000, "pid3": 40000},
+			},
+			nil,
+		},
+	} {
+		tt.agg.NetworkMap = nm
+		cms, err := tt.agg.CostMaps(testSamples())
+		if err != nil {
+			t.Fatalf("Aggregator.CostMaps failed: %v", err)
+		}
+		for metric, m := range map[string]map[string]alto.DstCosts{DelayRT: tt.rtts, LossRate: tt.losses} {
+			cm := cms[metric]
+			if ct := (alto.CostType{CostMetric: metric, CostMode: alto.CostModeNumerical, CostSource: alto.CostSourceEstimation}); cm.CostType != ct || cm.VersionTag != nm.VersionTag {
+				t.Fatalf("got %v, %v; expected %v, %v", cm.CostType, cm.VersionTag, ct, nm.VersionTag)
+			}
+			if m != nil && !reflect.DeepEqual(cm.Map, m) {
+				t.Fatalf("%s: got %v; expected %v", metric, cm.Map, m)
+			}
+		}
+	}
+
+	agg := Aggregator{NetworkMap: nm, CostSource: alto.CostSourceSLA}
+	cms, err := agg.CostMaps(testSamples())
+	if err != nil {
+		t.Fatalf("Aggregator.CostMaps failed: %v", err)
+	}
+	if cms[DelayRT].CostType.CostSource != alto.CostSourceSLA {
+		t.Fatalf("got %v; expected %v", cms[DelayRT].CostType.CostSource, alto.CostSourceSLA)
+	}
+	agg.CostSource = "measured"
+	if _, err := agg.CostMaps(testSamples()); err == nil {
+		t.Fatal("got nil; expected an error for unknown cost source")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package measure
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Sample represents a round-trip time and loss sample taken between
+// vantage points.
+type Sample struct {
+	Time time.Time // zero if unknown
+	Src  net.IP    // address of probe source
+	Dst  net.IP    // address of probe destination
+	RTT  float64   // round-trip time in milliseconds; NaN if unknown
+	Loss float64   // loss rate from 0 to 1; NaN if unknown
+}
+
+func (s *Sample) validate() error {
+	switch {
+	case s.Src == nil || s.Dst == nil:
+		return fmt.Errorf("missing source or destination address")
+	case s.RTT < 0:
+		return fmt.Errorf("invalid round-trip time %v", s.RTT)
+	case s.Loss < 0 || s.Loss > 1:
+		return fmt.Errorf("invalid loss rate %v", s.Loss)
+	}
+	return nil
+}
+
+type jsonSample struct {
+	Time *time.Time `json:"time"`
+	Src  string     `json:"src"`
+	Dst  string     `json:"dst"`
+	RTT  *float64   `json:"rtt"`
+	Loss *float64   `json:"loss"`
+}
+
+// ReadJSONLines reads samples in JSON lines from r. Each line holds a
+// JSON object like the following:
+//
+//	{"time": "2013-06-01T00:00:00Z", "src": "192.0.2.1", "dst": "198.51.100.1", "rtt": 12.5, "loss": 0}
+//
+// The members time, rtt and loss are optional, and unknown members are
+// ignored. Empty lines are ignored.
+func ReadJSONLines(r io.Reader) ([]Sample, error) {
+	var ss []Sample
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		var js jsonSample
+		if err := json.Unmarshal(b, &js); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		s := Sample{Src: net.ParseIP(js.Src), Dst: net.ParseIP(js.Dst), RTT: math.NaN(), Loss: math.NaN()}
+		if js.Time != nil {
+			s.Time = *js.Time
+		}
+		if js.RTT != nil {
+			s.RTT = *js.RTT
+		}
+		if js.Loss != nil {
+			s.Loss = *js.Loss
+		}
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		ss = append(ss, s)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// ReadCSV reads samples in CSV from r. The first record is a header
+// that names the columns time, src, dst, rtt and loss in any order.
+// The columns src and dst are mandatory, and unknown columns are
+// ignored. Times are in RFC 3339 format, and empty fields are
+// unknown. Lines beginning with "#" are ignored.
+func ReadCSV(r io.Reader) ([]Sample, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	hdr, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	cols := make(map[string]int)
+	for i, name := range hdr {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"src", "dst"} {
+		if _, ok := cols[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	var ss []Sample
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		s, err := parseRecord(rec, cols)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+func parseRecord(rec []string, cols map[string]int) (Sample, error) {
+	field := func(name string) string {
+		if i, ok := cols[name]; ok {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	s := Sample{Src: net.ParseIP(field("src")), Dst: net.ParseIP(field("dst")), RTT: math.NaN(), Loss: math.NaN()}
+	if f := field("time"); f != "" {
+		t, err := time.Parse(time.RFC3339, f)
+		if err != nil {
+			return s, err
+		}
+		s.Time = t
+	}
+	for _, v := range []struct {
+		name string
+		p    *float64
+	}{
+		{"rtt", &s.RTT},
+		{"loss", &s.Loss},
+	} {
+		if f := field(v.name); f != "" {
+			x, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return s, err
+			}
+			*v.p = x
+		}
+	}
+	return s, s.validate()
+}
+
+// ReadFile reads samples from the named file. Files with the suffix
+// ".csv" are read in CSV, and others in JSON lines.
+func ReadFile(name string) ([]Sample, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.HasSuffix(strings.ToLower(name), ".csv") {
+		return ReadCSV(f)
+	}
+	return ReadJSONLines(f)
+}
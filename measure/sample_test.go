@@ -0,0 +1,69 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package measure
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testJSONLines = `{"time": "2013-06-01T00:00:00Z", "src": "192.0.2.1", "dst": "198.51.100.1", "rtt": 10, "loss": 0}
+{"time": "2013-06-01T00:01:00Z", "src": "192.0.2.2", "dst": "198.51.100.1", "rtt": 30, "probe": "icmp"}
+
+{"src": "198.51.100.1", "dst": "192.0.2.1", "loss": 0.5}
+`
+
+const testCSV = `# collected by probe-1
+time,src,dst,rtt,loss
+2013-06-01T00:00:00Z,192.0.2.1,198.51.100.1,10,0
+2013-06-01T00:01:00Z,192.0.2.2,198.51.100.1,30,
+,198.51.100.1,192.0.2.1,,0.5
+`
+
+func TestReadSamples(t *testing.T) {
+	for _, read := range []func(string) ([]Sample, error){
+		func(s string) ([]Sample, error) { return ReadJSONLines(strings.NewReader(testJSONLines)) },
+		func(s string) ([]Sample, error) { return ReadCSV(strings.NewReader(testCSV)) },
+	} {
+		ss, err := read("")
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if len(ss) != 3 {
+			t.Fatalf("got %v samples; expected 3", len(ss))
+		}
+		if s := ss[0]; s.Time.IsZero() || s.Src.String() != "192.0.2.1" || s.Dst.String() != "198.51.100.1" || s.RTT != 10 || s.Loss != 0 {
+			t.Fatalf("got %+v; expected %v", s, "192.0.2.1 to 198.51.100.1")
+		}
+		if s := ss[1]; s.RTT != 30 || !math.IsNaN(s.Loss) {
+			t.Fatalf("got %v, %v; expected %v, %v", s.RTT, s.Loss, 30, math.NaN())
+		}
+		if s := ss[2]; !s.Time.IsZero() || !math.IsNaN(s.RTT) || s.Loss != 0.5 {
+			t.Fatalf("got %v, %v, %v; expected %v, %v, %v", s.Time, s.RTT, s.Loss, "zero", math.NaN(), 0.5)
+		}
+	}
+}
+
+var readErrorTests = []struct {
+	jsonl, csv string
+}{
+	{`{"src": "192.0.2.1", "rtt": 10}`, "src,dst,rtt\n192.0.2.1,,10\n"},
+	{`{"src": "192.0.2.1", "dst": "192.0.2.2", "rtt": -1}`, "src,dst,rtt\n192.0.2.1,192.0.2.2,-1\n"},
+	{`{"src": "192.0.2.1", "dst": "192.0.2.2", "loss": 1.5}`, "src,dst,loss\n192.0.2.1,192.0.2.2,1.5\n"},
+	{`{"src": "192.0.2.1", "dst": "192.0.2.2", "time": "yesterday"}`, "src,dst,time\n192.0.2.1,192.0.2.2,yesterday\n"},
+	{`{"src": "192.0.2.1", "dst": `, "src,rtt\n192.0.2.1,10\n"},
+}
+
+func TestReadSamplesError(t *testing.T) {
+	for _, tt := range readErrorTests {
+		if _, err := ReadJSONLines(strings.NewReader(tt.jsonl)); err == nil {
+			t.Fatalf("%s: got nil; expected an error", tt.jsonl)
+		}
+		if _, err := ReadCSV(strings.NewReader(tt.csv)); err == nil {
+			t.Fatalf("%q: got nil; expected an error", tt.csv)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A mediaRange represents a media range in the Accept header field.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var mrs []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		params := strings.Split(s, ";")
+		typ, subtype := splitMediaType(params[0])
+		if typ == "" {
+			continue
+		}
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, p := range params[1:] {
+			i := strings.Index(p, "=")
+			if i < 0 || strings.ToLower(strings.TrimSpace(p[:i])) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(p[i+1:]), 64); err == nil && q >= 0 && q <= 1 {
+				mr.q = q
+			}
+		}
+		mrs = append(mrs, mr)
+	}
+	return mrs
+}
+
+func splitMediaType(s string) (string, string) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.Index(s, "/")
+	if i <= 0 || i == len(s)-1 {
+		return "", ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// quality returns the quality value for the media type typ/subtype
+// given by the most specific media range in mrs.
+func quality(mrs []mediaRange, typ, subtype string) float64 {
+	q, specificity := 0.0, -1
+	for _, mr := range mrs {
+		var n int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			n = 2
+		case mr.typ == typ && mr.subtype == "*":
+			n = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			n = 0
+		default:
+			continue
+		}
+		if n > specificity {
+			q, specificity = mr.q, n
+		}
+	}
+	return q
+}
+
+// NegotiateMediaType returns the most preferable media type in offers
+// acceptable to the Accept header field value accept. It honors
+// wildcards and quality values, and prefers earlier offers among
+// equally acceptable ones. An empty accept accepts any media type. It
+// returns an empty string when no offer is acceptable.
+func NegotiateMediaType(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) > 0 {
+			return offers[0]
+		}
+		return ""
+	}
+	mrs := parseAccept(accept)
+	best, bestq := "", 0.0
+	for _, offer := range offers {
+		typ, subtype := splitMediaType(offer)
+		if q := quality(mrs, typ, subtype); q > bestq {
+			best, bestq = offer, q
+		}
+	}
+	return best
+}
+
+// MatchMediaType reports whether the Content-Type header field value
+// contentType denotes the media type typ. Media type parameters are
+// ignored.
+func MatchMediaType(contentType, typ string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(mt, typ)
+}
+
+// Negotiate checks the Accept and Content-Type header fields of req
+// for the information resource with media type typ. The accepts is
+// the media type of acceptable request body, or an empty string when
+// the resource takes no request body. On failure, Negotiate replies
+// to req with http.StatusNotAcceptable or
+// http.StatusUnsupportedMediaType and returns false.
+func Negotiate(w http.ResponseWriter, req *http.Request, typ, accepts string) bool {
+	accept := req.Header.Get("Accept")
+	if NegotiateMediaType(accept, typ) == "" {
+		writeNegotiationError(w, NegotiateMediaType(accept, MediaTypeError), http.StatusNotAcceptable)
+		return false
+	}
+	if accepts != "" && !MatchMediaType(req.Header.Get("Content-Type"), accepts) {
+		writeNegotiationError(w, NegotiateMediaType(accept, MediaTypeError), http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+func writeNegotiationError(w http.ResponseWriter, mt string, status int) {
+	if mt != MediaTypeError {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	WriteError(w, status, ErrSyntax)
+}
+
+// WriteError replies to the request with HTTP status code status and
+// an error notification with ALTO error code code.
+func WriteError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", MediaTypeError)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&Error{Code: code})
+}
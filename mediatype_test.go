@@ -0,0 +1,83 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var negotiateMediaTypeTests = []struct {
+	accept string
+	out    string
+}{
+	{"", MediaTypeNetworkMap},
+	{"*/*", MediaTypeNetworkMap},
+	{"application/*", MediaTypeNetworkMap},
+	{MediaTypeNetworkMap, MediaTypeNetworkMap},
+	{MediaTypeNetworkMap + "; charset=utf-8", MediaTypeNetworkMap},
+	{"Application/ALTO-NetworkMap+JSON", MediaTypeNetworkMap},
+	{MediaTypeError, MediaTypeError},
+	{MediaTypeError + ", " + MediaTypeNetworkMap + ";q=0.5", MediaTypeError},
+	{MediaTypeError + ";q=0.1, application/*;q=0.5", MediaTypeNetworkMap},
+	{"application/*;q=0, " + MediaTypeError, MediaTypeError},
+	{"*/*, " + MediaTypeNetworkMap + ";q=0", MediaTypeError},
+	{"text/html", ""},
+	{"application/json", ""},
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	for _, tt := range negotiateMediaTypeTests {
+		if mt := NegotiateMediaType(tt.accept, MediaTypeNetworkMap, MediaTypeError); mt != tt.out {
+			t.Fatalf("NegotiateMediaType(%q) = %q; expected %q", tt.accept, mt, tt.out)
+		}
+	}
+}
+
+var negotiateTests = []struct {
+	accept      string
+	contentType string
+	code        int
+	mediaType   string
+}{
+	{MediaTypeEndpointProp, MediaTypeEndpointPropParams, http.StatusOK, ""},
+	{"*/*", MediaTypeEndpointPropParams + "; charset=utf-8", http.StatusOK, ""},
+	{"text/plain", MediaTypeEndpointPropParams, http.StatusNotAcceptable, "text/plain; charset=utf-8"},
+	{"text/plain, " + MediaTypeError, MediaTypeEndpointPropParams, http.StatusNotAcceptable, MediaTypeError},
+	{MediaTypeEndpointProp + "," + MediaTypeError, "application/json", http.StatusUnsupportedMediaType, MediaTypeError},
+	{MediaTypeEndpointProp, "", http.StatusUnsupportedMediaType, "text/plain; charset=utf-8"},
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tt := range negotiateTests {
+		req := httptest.NewRequest("POST", "/endpointprop/lookup", nil)
+		req.Header.Set("Accept", tt.accept)
+		req.Header.Set("Content-Type", tt.contentType)
+		rw := httptest.NewRecorder()
+		if ok := Negotiate(rw, req, MediaTypeEndpointProp, MediaTypeEndpointPropParams); ok != (tt.code == http.StatusOK) {
+			t.Fatalf("Negotiate(%q, %q) = %v", tt.accept, tt.contentType, ok)
+		}
+		if rw.Code != tt.code {
+			t.Fatalf("%q, %q: got %v; expected %v", tt.accept, tt.contentType, rw.Code, tt.code)
+		}
+		if tt.code == http.StatusOK {
+			continue
+		}
+		if ct := rw.Header().Get("Content-Type"); ct != tt.mediaType {
+			t.Fatalf("got %v; expected %v", ct, tt.mediaType)
+		}
+		if tt.mediaType == MediaTypeError {
+			var e Error
+			if err := json.NewDecoder(rw.Body).Decode(&e); err != nil {
+				t.Fatalf("json.Decoder.Decode failed: %v", err)
+			}
+			if e.Code != ErrSyntax {
+				t.Fatalf("got %v; expected %v", e.Code, ErrSyntax)
+			}
+		}
+	}
+}
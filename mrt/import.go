@@ -0,0 +1,255 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package mrt
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mikioh/alto"
+)
+
+// A Rule represents a rule for grouping prefixes into provider-defined
+// identifiers (PIDs).
+type Rule interface {
+	// PID returns the PID for the prefix p reached by the route
+	// rt. It returns false when the rule does not map p.
+	PID(p *net.IPNet, rt *Route) (string, bool)
+}
+
+// A ByOriginAS represents a rule that groups prefixes by originating
+// autonomous system. PIDs are named "as" followed by the autonomous
+// system number.
+type ByOriginAS struct{}
+
+// PID implements the PID method of Rule interface.
+func (ByOriginAS) PID(p *net.IPNet, rt *Route) (string, bool) {
+	if rt.OriginAS == 0 {
+		return "", false
+	}
+	return "as" + strconv.FormatUint(uint64(rt.OriginAS), 10), true
+}
+
+// A ByNextHop represents a rule that groups prefixes by next hop.
+// PIDs are named "nh-" followed by the next hop address, with dots
+// replaced by hyphens.
+type ByNextHop struct{}
+
+// PID implements the PID method of Rule interface.
+func (ByNextHop) PID(p *net.IPNet, rt *Route) (string, bool) {
+	if rt.NextHop == nil {
+		return "", false
+	}
+	return "nh-" + strings.Replace(rt.NextHop.String(), ".", "-", -1), true
+}
+
+// A ByCommunity represents a rule that groups prefixes by the first
+// community that begins with Prefix. PIDs are named "c-" followed by
+// the community.
+type ByCommunity struct {
+	Prefix string // prefix of communities, such as "64496:"; empty matches any community
+}
+
+// PID implements the PID method of Rule interface.
+func (bc ByCommunity) PID(p *net.IPNet, rt *Route) (string, bool) {
+	for _, c := range rt.Communities {
+		if strings.HasPrefix(c, bc.Prefix) {
+			return "c-" + c, true
+		}
+	}
+	return "", false
+}
+
+// A Mapping represents a rule that maps prefixes, communities and
+// originating autonomous systems to PIDs. A prefix mapping applies to
+// all prefixes it covers, and the longest one takes precedence. Prefix
+// mappings take precedence over community mappings, which take
+// precedence over autonomous system mappings.
+type Mapping struct {
+	prefixes    []mappingPrefix
+	communities map[string]string
+	origins     map[uint32]string
+}
+
+type mappingPrefix struct {
+	ipn *net.IPNet
+	pid string
+}
+
+// ParseMapping parses a mapping file read from r. Each line consists
+// of a key and a PID separated by white space, where the key is an
+// IP address prefix, a community such as 64496:100 or 64496:1:2, or
+// an autonomous system number prefixed with "as". Empty lines and
+// lines beginning with "#" are ignored.
+func ParseMapping(r io.Reader) (*Mapping, error) {
+	m := &Mapping{communities: make(map[string]string), origins: make(map[uint32]string)}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fs := strings.Fields(line)
+		if len(fs) != 2 {
+			return nil, fmt.Errorf("line %d: expected key and pid", n)
+		}
+		key, pid := fs[0], fs[1]
+		switch {
+		case strings.Contains(key, "/"):
+			_, ipn, err := net.ParseCIDR(key)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			m.prefixes = append(m.prefixes, mappingPrefix{ipn: ipn, pid: pid})
+		case strings.HasPrefix(strings.ToLower(key), "as"):
+			as, err := strconv.ParseUint(key[2:], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid autonomous system number %s", n, key)
+			}
+			m.origins[uint32(as)] = pid
+		case strings.Contains(key, ":"):
+			for _, f := range strings.Split(key, ":") {
+				if _, err := strconv.ParseUint(f, 10, 32); err != nil {
+					return nil, fmt.Errorf("line %d: invalid community %s", n, key)
+				}
+			}
+			m.communities[key] = pid
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %s", n, key)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PID implements the PID method of Rule interface.
+func (m *Mapping) PID(p *net.IPNet, rt *Route) (string, bool) {
+	pid, best := "", -1
+	l, _ := p.Mask.Size()
+	for _, mp := range m.prefixes {
+		ml, _ := mp.ipn.Mask.Size()
+		if ml > best && ml <= l && len(mp.ipn.IP) == len(p.IP) && mp.ipn.Contains(p.IP) {
+			pid, best = mp.pid, ml
+		}
+	}
+	if best >= 0 {
+		return pid, true
+	}
+	for _, c := range rt.Communities {
+		if pid, ok := m.communities[c]; ok {
+			return pid, true
+		}
+	}
+	pid, ok := m.origins[rt.OriginAS]
+	return pid, ok
+}
+
+// An Importer represents a builder of network maps from MRT RIB
+// dumps.
+type Importer struct {
+	Rule    Rule   // grouping rule; nil means ByOriginAS
+	Peer    net.IP // address of peer whose routes are used; nil means all peers
+	Default string // PID for prefixes the rule does not map; empty means to drop them
+}
+
+// Import reads a RIB dump from r and returns a network map. For each
+// prefix, the route with the shortest AS path is used, and the first
+// one listed wins among the routes of equal length. The endpoint
+// address groups of the network map are aggregated, and the version
+// tag is derived from the content.
+func (imp *Importer) Import(r io.Reader) (*alto.NetworkMap, error) {
+	rule := imp.Rule
+	if rule == nil {
+		rule = ByOriginAS{}
+	}
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	mr := NewReader(r)
+	for {
+		rib, err := mr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rt := imp.bestRoute(rib)
+		if rt == nil {
+			continue
+		}
+		pid, ok := rule.PID(rib.Prefix, rt)
+		if !ok {
+			if pid = imp.Default; pid == "" {
+				continue
+			}
+		}
+		typ := "ipv4"
+		if rib.Prefix.IP.To4() == nil {
+			typ = "ipv6"
+		}
+		ep, err := alto.ParseEndpoint(typ, rib.Prefix.String())
+		if err != nil {
+			return nil, err
+		}
+		eag, ok := nm.Map[pid]
+		if !ok {
+			eag = make(alto.EndpointAddrGroup)
+			nm.Map[pid] = eag
+		}
+		eag[typ] = append(eag[typ], ep)
+	}
+	nm.Aggregate()
+	vtag, err := alto.ContentVersionTag(nm)
+	if err != nil {
+		return nil, err
+	}
+	nm.VersionTag = vtag
+	return nm, nil
+}
+
+func (imp *Importer) bestRoute(rib *RIB) *Route {
+	var best *Route
+	for _, rt := range rib.Routes {
+		if imp.Peer != nil && !imp.Peer.Equal(rt.Peer.Addr) {
+			continue
+		}
+		if best == nil || len(rt.ASPath) < len(best.ASPath) {
+			best = rt
+		}
+	}
+	return best
+}
+
+// ImportFile reads a RIB dump from the named file and returns a
+// network map. Files with the suffixes ".gz" and ".bz2" are
+// decompressed.
+func (imp *Importer) ImportFile(name string) (*alto.NetworkMap, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = bufio.NewReader(f)
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case strings.HasSuffix(name, ".bz2"):
+		r = bzip2.NewReader(r)
+	}
+	return imp.Import(r)
+}
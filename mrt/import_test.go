@@ -0,0 +1,92 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package mrt
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+func testRIBDump() []byte {
+	var b bytes.Buffer
+	b.Write(peerIndexTable("192.0.2.1 64496", "192.0.2.2 64497"))
+	for _, e := range []struct {
+		prefix string
+		rts    []testRoute
+	}{
+		{"198.51.100.0/25", []testRoute{{peer: 0, attrs: attrs(asPath(segmentASSequence, 64496, 64510), nextHop("192.0.2.1"))}}},
+		{"198.51.100.128/25", []testRoute{{peer: 0, attrs: attrs(asPath(segmentASSequence, 64496, 64510), nextHop("192.0.2.1"))}}},
+		{"203.0.113.0/24", []testRoute{
+			{peer: 0, attrs: attrs(asPath(segmentASSequence, 64496, 64500, 64511), nextHop("192.0.2.1"))},
+			{peer: 1, attrs: attrs(asPath(segmentASSequence, 64497, 64511), nextHop("192.0.2.2"), communities(64497<<16|200))},
+		}},
+		{"192.0.2.0/24", []testRoute{{peer: 1, attrs: attrs(asPath(segmentASSequence, 64497), nextHop("192.0.2.2"))}}},
+	} {
+		b.Write(ribEntry(subtypeRIBIPv4Unicast, e.prefix, e.rts...))
+	}
+	return b.Bytes()
+}
+
+func checkPIDs(t *testing.T, nm *alto.NetworkMap, pids map[string]string) {
+	if len(nm.Map) != len(pids) {
+		t.Fatalf("got %v PIDs; expected %v", len(nm.Map), len(pids))
+	}
+	for pid, s := range pids {
+		var ss []string
+		for _, ep := range nm.Endpoints(pid, "ipv4") {
+			ss = append(ss, ep.String())
+		}
+		if strings.Join(ss, " ") != s {
+			t.Fatalf("%s: got %v; expected %v", pid, ss, s)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	mapping, err := ParseMapping(strings.NewReader(`
+# prefixes take precedence
+198.51.100.0/24  pid-customer
+64497:200        pid-peer
+as64497          pid-transit
+`))
+	if err != nil {
+		t.Fatalf("ParseMapping failed: %v", err)
+	}
+	for _, tt := range []struct {
+		imp  Importer
+		pids map[string]string
+	}{
+		{Importer{}, map[string]string{"as64510": "198.51.100.0/24", "as64511": "203.0.113.0/24", "as64497": "192.0.2.0/24"}},
+		{Importer{Rule: ByNextHop{}}, map[string]string{"nh-192-0-2-1": "198.51.100.0/24", "nh-192-0-2-2": "192.0.2.0/24 203.0.113.0/24"}},
+		{Importer{Rule: ByCommunity{Prefix: "64497:"}, Default: "default"}, map[string]string{"c-64497:200": "203.0.113.0/24", "default": "192.0.2.0/24 198.51.100.0/24"}},
+		{Importer{Rule: mapping}, map[string]string{"pid-customer": "198.51.100.0/24", "pid-peer": "203.0.113.0/24", "pid-transit": "192.0.2.0/24"}},
+		{Importer{Peer: net.ParseIP("192.0.2.1")}, map[string]string{"as64510": "198.51.100.0/24", "as64511": "203.0.113.0/24"}},
+	} {
+		nm, err := tt.imp.Import(bytes.NewReader(testRIBDump()))
+		if err != nil {
+			t.Fatalf("Importer.Import failed: %v", err)
+		}
+		checkPIDs(t, nm, tt.pids)
+		vtag, err := alto.ContentVersionTag(nm)
+		if err != nil {
+			t.Fatalf("alto.ContentVersionTag failed: %v", err)
+		}
+		if nm.VersionTag != vtag {
+			t.Fatalf("got %v; expected %v", nm.VersionTag, vtag)
+		}
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	for _, s := range []string{"192.0.2.0/33 pid1", "asx pid1", "64496:x pid1", "pid1", "foo pid1"} {
+		if _, err := ParseMapping(strings.NewReader(s)); err == nil {
+			t.Fatalf("%q: got nil; expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package mrt implements a reader of BGP routing information base
+// (RIB) dumps in the Multi-Threaded Routing Toolkit (MRT) format as
+// described in RFC 6396, and an importer that builds Application-Layer
+// Traffic Optimization (ALTO) network maps from them.
+//
+// Only the TABLE_DUMP_V2 type is supported, including the ADD-PATH
+// extension described in RFC 8050. Other records are skipped.
+package mrt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+const (
+	typeTableDumpV2 = 13
+
+	subtypePeerIndexTable        = 1
+	subtypeRIBIPv4Unicast        = 2
+	subtypeRIBIPv6Unicast        = 4
+	subtypeRIBIPv4UnicastAddPath = 8
+	subtypeRIBIPv6UnicastAddPath = 10
+
+	attrASPath           = 2
+	attrNextHop          = 3
+	attrCommunities      = 8
+	attrMPReachNLRI      = 14
+	attrLargeCommunities = 32
+
+	segmentASSet      = 1
+	segmentASSequence = 2
+
+	maxRecordLen = 1 << 24 // far larger than any sane record
+)
+
+var (
+	errNoPeerIndexTable = errors.New("no peer index table")
+	errShortRecord      = errors.New("short record")
+	errRecordTooLarge   = errors.New("record too large")
+)
+
+// A Peer represents a BGP peer listed in the peer index table.
+type Peer struct {
+	BGPID net.IP // BGP identifier
+	Addr  net.IP // peer address
+	AS    uint32 // peer autonomous system number
+}
+
+// A Route represents a route to a prefix learned from a peer.
+type Route struct {
+	Peer        *Peer
+	PathID      uint32   // path identifier of ADD-PATH; zero if none
+	ASPath      []uint32 // autonomous system numbers in order
+	OriginAS    uint32   // originating autonomous system; zero if unknown
+	NextHop     net.IP
+	Communities []string // communities as "asn:value" and large communities as "asn:value:value"
+}
+
+// A RIB represents a RIB entry that holds routes to a prefix.
+type RIB struct {
+	Prefix *net.IPNet
+	Routes []*Route
+}
+
+// A Reader represents a reader of MRT TABLE_DUMP_V2 records.
+type Reader struct {
+	r     io.Reader
+	hdr   [12]byte
+	peers []*Peer // nil until the peer index table is read
+}
+
+// NewReader returns a new reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// Peers returns the peers listed in the peer index table read so far.
+func (r *Reader) Peers() []*Peer {
+	return r.peers
+}
+
+// Next returns the next RIB entry. It returns io.EOF when no more
+// entries are available.
+func (r *Reader) Next() (*RIB, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errShortRecord
+			}
+			return nil, err
+		}
+		typ := binary.BigEndian.Uint16(r.hdr[4:6])
+		subtype := binary.BigEndian.Uint16(r.hdr[6:8])
+		n := int64(binary.BigEndian.Uint32(r.hdr[8:12]))
+		if typ != typeTableDumpV2 {
+			if _, err := io.CopyN(ioutil.Discard, r.r, n); err != nil {
+				return nil, errShortRecord
+			}
+			continue
+		}
+		if n > maxRecordLen {
+			return nil, errRecordTooLarge
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r.r, b); err != nil {
+			return nil, errShortRecord
+		}
+		switch subtype {
+		case subtypePeerIndexTable:
+			peers, err := parsePeerIndexTable(b)
+			if err != nil {
+				return nil, err
+			}
+			r.peers = peers
+		case subtypeRIBIPv4Unicast, subtypeRIBIPv6Unicast, subtypeRIBIPv4UnicastAddPath, subtypeRIBIPv6UnicastAddPath:
+			if r.peers == nil {
+				return nil, errNoPeerIndexTable
+			}
+			ipv6 := subtype == subtypeRIBIPv6Unicast || subtype == subtypeRIBIPv6UnicastAddPath
+			addPath := subtype == subtypeRIBIPv4UnicastAddPath || subtype == subtypeRIBIPv6UnicastAddPath
+			return r.parseRIB(b, ipv6, addPath)
+		}
+	}
+}
+
+func parsePeerIndexTable(b []byte) ([]*Peer, error) {
+	if len(b) < 6 {
+		return nil, errShortRecord
+	}
+	off := 4 + 2 + int(binary.BigEndian.Uint16(b[4:6])) // collector BGP ID and view name
+	if len(b) < off+2 {
+		return nil, errShortRecord
+	}
+	n := int(binary.BigEndian.Uint16(b[off : off+2]))
+	off += 2
+	peers := make([]*Peer, 0, n)
+	for i := 0; i < n; i++ {
+		if len(b) < off+5 {
+			return nil, errShortRecord
+		}
+		typ := b[off]
+		p := &Peer{BGPID: net.IP(append([]byte(nil), b[off+1:off+5]...))}
+		off += 5
+		alen, aslen := net.IPv4len, 2
+		if typ&0x01 != 0 {
+			alen = net.IPv6len
+		}
+		if typ&0x02 != 0 {
+			aslen = 4
+		}
+		if len(b) < off+alen+aslen {
+			return nil, errShortRecord
+		}
+		p.Addr = net.IP(append([]byte(nil), b[off:off+alen]...))
+		off += alen
+		if aslen == 4 {
+			p.AS = binary.BigEndian.Uint32(b[off : off+4])
+		} else {
+			p.AS = uint32(binary.BigEndian.Uint16(b[off : off+2]))
+		}
+		off += aslen
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+func (r *Reader) parseRIB(b []byte, ipv6, addPath bool) (*RIB, error) {
+	if len(b) < 5 {
+		return nil, errShortRecord
+	}
+	bits := 8 * net.IPv4len
+	if ipv6 {
+		bits = 8 * net.IPv6len
+	}
+	l := int(b[4])
+	if l > bits {
+		return nil, fmt.Errorf("invalid prefix length %d", l)
+	}
+	off := 5 + (l+7)/8
+	if len(b) < off+2 {
+		return nil, errShortRecord
+	}
+	ip := make(net.IP, bits/8)
+	copy(ip, b[5:off])
+	rib := &RIB{Prefix: &net.IPNet{IP: ip, Mask: net.CIDRMask(l, bits)}}
+	rib.Prefix.IP = ip.Mask(rib.Prefix.Mask)
+	n := int(binary.BigEndian.Uint16(b[off : off+2]))
+	off += 2
+	for i := 0; i < n; i++ {
+		if len(b) < off+6 {
+			return nil, errShortRecord
+		}
+		pi := int(binary.BigEndian.Uint16(b[off : off+2]))
+		if pi >= len(r.peers) {
+			return nil, fmt.Errorf("invalid peer index %d", pi)
+		}
+		rt := &Route{Peer: r.peers[pi]}
+		off += 6 // peer index and originated time
+		if addPath {
+			if len(b) < off+4 {
+				return nil, errShortRecord
+			}
+			rt.PathID = binary.BigEndian.Uint32(b[off : off+4])
+			off += 4
+		}
+		if len(b) < off+2 {
+			return nil, errShortRecord
+		}
+		alen := int(binary.BigEndian.Uint16(b[off : off+2]))
+		off += 2
+		if len(b) < off+alen {
+			return nil, errShortRecord
+		}
+		if err := rt.parseAttrs(b[off : off+alen]); err != nil {
+			return nil, err
+		}
+		off += alen
+		rib.Routes = append(rib.Routes, rt)
+	}
+	return rib, nil
+}
+
+func (rt *Route) parseAttrs(b []byte) error {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return errShortRecord
+		}
+		flags, typ := b[0], b[1]
+		var l, off int
+		if flags&0x10 != 0 { // extended length
+			if len(b) < 4 {
+				return errShortRecord
+			}
+			l, off = int(binary.BigEndian.Uint16(b[2:4])), 4
+		} else {
+			l, off = int(b[2]), 3
+		}
+		if len(b) < off+l {
+			return errShortRecord
+		}
+		v := b[off : off+l]
+		b = b[off+l:]
+		switch typ {
+		case attrASPath:
+			if err := rt.parseASPath(v); err != nil {
+				return err
+			}
+		case attrNextHop:
+			if len(v) == net.IPv4len {
+				rt.NextHop = net.IP(append([]byte(nil), v...))
+			}
+		case attrMPReachNLRI:
+			rt.parseMPReachNLRI(v)
+		case attrCommunities:
+			for ; len(v) >= 4; v = v[4:] {
+				rt.Communities = append(rt.Communities, fmt.Sprintf("%d:%d", binary.BigEndian.Uint16(v[:2]), binary.BigEndian.Uint16(v[2:4])))
+			}
+		case attrLargeCommunities:
+			for ; len(v) >= 12; v = v[12:] {
+				rt.Communities = append(rt.Communities, fmt.Sprintf("%d:%d:%d", binary.BigEndian.Uint32(v[:4]), binary.BigEndian.Uint32(v[4:8]), binary.BigEndian.Uint32(v[8:12])))
+			}
+		}
+	}
+	return nil
+}
+
+// parseASPath parses the AS_PATH attribute, which always consists of
+// 4-octet autonomous system numbers in TABLE_DUMP_V2.
+func (rt *Route) parseASPath(b []byte) error {
+	var last []uint32
+	var lastType byte
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+4*int(b[1]) {
+			return errShortRecord
+		}
+		typ, n := b[0], int(b[1])
+		seg := make([]uint32, n)
+		for i := range seg {
+			seg[i] = binary.BigEndian.Uint32(b[2+4*i:])
+		}
+		rt.ASPath = append(rt.ASPath, seg...)
+		if n > 0 {
+			last, lastType = seg, typ
+		}
+		b = b[2+4*n:]
+	}
+	switch {
+	case lastType == segmentASSequence:
+		rt.OriginAS = last[len(last)-1]
+	case lastType == segmentASSet && len(last) == 1:
+		rt.OriginAS = last[0]
+	}
+	return nil
+}
+
+// parseMPReachNLRI parses the MP_REACH_NLRI attribute. TABLE_DUMP_V2
+// encodes only the next hop length and next hop address, but some
+// implementations encode the full attribute.
+func (rt *Route) parseMPReachNLRI(b []byte) {
+	if len(b) > 0 && int(b[0]) != len(b)-1 && len(b) >= 4 {
+		b = b[3:] // address family and subsequent address family
+	}
+	if len(b) == 0 || len(b) < 1+int(b[0]) {
+		return
+	}
+	nh := b[1 : 1+int(b[0])]
+	if len(nh) == 2*net.IPv6len {
+		nh = nh[:net.IPv6len] // global address followed by link-local address
+	}
+	if len(nh) == net.IPv4len || len(nh) == net.IPv6len {
+		rt.NextHop = net.IP(append([]byte(nil), nh...))
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package mrt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func record(typ, subtype uint16, b []byte) []byte {
+	hdr := make([]byte, 12)
+	binary.BigEndian.PutUint32(hdr[0:4], 1367366400)
+	binary.BigEndian.PutUint16(hdr[4:6], typ)
+	binary.BigEndian.PutUint16(hdr[6:8], subtype)
+	binary.BigEndian.PutUint32(hdr[8:12], uint32(len(b)))
+	return append(hdr, b...)
+}
+
+// peerIndexTable returns a peer index table record for peers of the
+// form "address asn".
+func peerIndexTable(peers ...string) []byte {
+	b := []byte{192, 0, 2, 254, 0, 4, 't', 'e', 's', 't'}
+	b = append(b, byte(len(peers)>>8), byte(len(peers)))
+	for i, p := range peers {
+		fs := strings.Fields(p)
+		ip := net.ParseIP(fs[0])
+		as, _ := strconv.ParseUint(fs[1], 10, 32)
+		typ := byte(0x02)
+		if ip.To4() == nil {
+			typ |= 0x01
+		} else {
+			ip = ip.To4()
+		}
+		b = append(b, typ, 10, 0, 0, byte(i+1))
+		b = append(b, ip...)
+		b = append(b, byte(as>>24), byte(as>>16), byte(as>>8), byte(as))
+	}
+	return record(typeTableDumpV2, subtypePeerIndexTable, b)
+}
+
+// A testRoute represents a route in a RIB entry record.
+type testRoute struct {
+	peer   int
+	pathID uint32
+	attrs  []byte
+}
+
+func ribEntry(subtype uint16, prefix string, rts ...testRoute) []byte {
+	_, ipn, _ := net.ParseCIDR(prefix)
+	l, _ := ipn.Mask.Size()
+	ip := ipn.IP
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	b := []byte{0, 0, 0, 1, byte(l)}
+	b = append(b, ip[:(l+7)/8]...)
+	b = append(b, byte(len(rts)>>8), byte(len(rts)))
+	for _, rt := range rts {
+		b = append(b, byte(rt.peer>>8), byte(rt.peer), 0, 0, 0, 0)
+		if subtype == subtypeRIBIPv4UnicastAddPath || subtype == subtypeRIBIPv6UnicastAddPath {
+			b = append(b, byte(rt.pathID>>24), byte(rt.pathID>>16), byte(rt.pathID>>8), byte(rt.pathID))
+		}
+		b = append(b, byte(len(rt.attrs)>>8), byte(len(rt.attrs)))
+		b = append(b, rt.attrs...)
+	}
+	return record(typeTableDumpV2, subtype, b)
+}
+
+func attr(typ byte, v []byte) []byte {
+	if len(v) > 255 {
+		return append([]byte{0x50, typ, byte(len(v) >> 8), byte(len(v))}, v...)
+	}
+	return append([]byte{0x40, typ, byte(len(v))}, v...)
+}
+
+func asPath(typ byte, asns ...uint32) []byte {
+	b := []byte{typ, byte(len(asns))}
+	for _, as := range asns {
+		b = append(b, byte(as>>24), byte(as>>16), byte(as>>8), byte(as))
+	}
+	return attr(attrASPath, b)
+}
+
+func nextHop(s string) []byte {
+	ip := net.ParseIP(s)
+	if ip4 := ip.To4(); ip4 != nil {
+		return attr(attrNextHop, ip4)
+	}
+	return attr(attrMPReachNLRI, append([]byte{net.IPv6len}, ip...))
+}
+
+func communities(cs ...uint32) []byte {
+	var b []byte
+	for _, c := range cs {
+		b = append(b, byte(c>>24), byte(c>>16), byte(c>>8), byte(c))
+	}
+	return attr(attrCommunities, b)
+}
+
+func attrs(bs ...[]byte) []byte {
+	return bytes.Join(bs, nil)
+}
+
+func TestReader(t *testing.T) {
+	var b bytes.Buffer
+	b.Write(record(16, 4, []byte{0, 1, 2, 3})) // BGP4MP
+	b.Write(peerIndexTable("192.0.2.1 64496", "2001:db8::1 64497"))
+	b.Write(ribEntry(subtypeRIBIPv4Unicast, "198.51.100.0/24",
+		testRoute{peer: 0, attrs: attrs(asPath(segmentASSequence, 64496, 64510), nextHop("192.0.2.1"), communities(64496<<16|100))},
+		testRoute{peer: 1, attrs: attrs(asPath(segmentASSequence, 64497), asPath(segmentASSet, 64511))},
+	))
+	b.Write(ribEntry(subtypeRIBIPv6UnicastAddPath, "2001:db8:1::/48",
+		testRoute{peer: 1, pathID: 7, attrs: attrs(asPath(segmentASSequence, 64497, 64512), nextHop("2001:db8::1"))},
+	))
+
+	r := NewReader(&b)
+	rib, err := r.Next()
+	if err != nil {
+		t.Fatalf("Reader.Next failed: %v", err)
+	}
+	if len(r.Peers()) != 2 || r.Peers()[1].AS != 64497 || !r.Peers()[1].Addr.Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("got %v; expected 2 peers", r.Peers())
+	}
+	if rib.Prefix.String() != "198.51.100.0/24" || len(rib.Routes) != 2 {
+		t.Fatalf("got %v, %v routes; expected %v, %v routes", rib.Prefix, len(rib.Routes), "198.51.100.0/24", 2)
+	}
+	rt := rib.Routes[0]
+	if rt.OriginAS != 64510 || !rt.NextHop.Equal(net.ParseIP("192.0.2.1")) || len(rt.Communities) != 1 || rt.Communities[0] != "64496:100" {
+		t.Fatalf("got %+v; expected origin %v, next hop %v and community %v", rt, 64510, "192.0.2.1", "64496:100")
+	}
+	if rt := rib.Routes[1]; rt.OriginAS != 64511 || len(rt.ASPath) != 2 {
+		t.Fatalf("got %+v; expected origin %v", rt, 64511)
+	}
+	if rib, err = r.Next(); err != nil {
+		t.Fatalf("Reader.Next failed: %v", err)
+	}
+	rt = rib.Routes[0]
+	if rib.Prefix.String() != "2001:db8:1::/48" || rt.PathID != 7 || rt.OriginAS != 64512 || !rt.NextHop.Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("got %v, %+v; expected %v with path id %v", rib.Prefix, rt, "2001:db8:1::/48", 7)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("got %v; expected %v", err, io.EOF)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, b := range [][]byte{
+		ribEntry(subtypeRIBIPv4Unicast, "198.51.100.0/24"),
+		append(peerIndexTable("192.0.2.1 64496"), ribEntry(subtypeRIBIPv4Unicast, "198.51.100.0/24", testRoute{peer: 1})...),
+		peerIndexTable("192.0.2.1 64496")[:20],
+		{0, 0, 0, 0, 0, typeTableDumpV2, 0, subtypePeerIndexTable, 0xff, 0xff, 0xff, 0xff},
+		{0, 0, 0, 0, 0, 16, 0, 4, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if _, err := NewReader(bytes.NewReader(b)).Next(); err == nil || err == io.EOF {
+			t.Fatalf("got %v; expected an error", err)
+		}
+	}
+}
@@ -4,7 +4,12 @@
 
 package alto
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"sync/atomic"
+)
 
 const (
 	MediaTypeNetworkMap       = "application/alto-networkmap+json"       // media type for ALTO map service
@@ -12,10 +17,15 @@ const (
 )
 
 // A NetworkMap represents a list of network locations within the
-// provider-defined identifier (PID).
+// provider-defined identifier (PID). The mutation methods replace
+// VersionTag with a new random version tag whenever they change the
+// map. Use ContentVersionTag to derive a version tag from the content
+// when publishing the map.
 type NetworkMap struct {
 	VersionTag string                       `json:"map-vtag"`
 	Map        map[string]EndpointAddrGroup `json:"map"`
+
+	index atomic.Value // *pidIndex; nil until first lookup
 }
 
 // MarshalJSON implements the MarshalJSON method of json.Marshaler
@@ -38,17 +48,29 @@ func (nm *NetworkMap) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &raw); err != nil {
 		return err
 	}
-	for key, v := range raw.(map[string]interface{}) {
+	if raw == nil {
+		return nil
+	}
+	m, err := objectOf("network map", raw)
+	if err != nil {
+		return err
+	}
+	for key, v := range m {
 		switch key {
 		case "map-vtag":
 			if v, ok := v.(string); ok {
 				nm.VersionTag = v
 			}
 		case "map":
+			nmd, err := objectOf(key, v)
+			if err != nil {
+				return err
+			}
+			nm.index.Store((*pidIndex)(nil))
 			if len(nm.Map) == 0 {
 				nm.Map = make(map[string]EndpointAddrGroup)
 			}
-			for pid, vv := range v.(map[string]interface{}) {
+			for pid, vv := range nmd {
 				switch vv := vv.(type) {
 				case map[string]interface{}:
 					eag := make(EndpointAddrGroup)
@@ -100,22 +122,328 @@ func (nm *NetworkMap) toSlice(pid string) []EndpointAddrGroup {
 	return eags
 }
 
-// Set sets the provider-defined identifier (PID) pid to endpoiint
-// ep. It replaces any existing endpoints.
+// Set adds endpoint ep to the provider-defined identifier (PID) pid.
+// It is equivalent to Add.
 func (nm *NetworkMap) Set(pid string, ep Endpoint) {
-	if eag, ok := nm.Map[pid]; !ok {
-		eag := make(EndpointAddrGroup)
-		eag[ep.Network()] = []Endpoint{ep}
+	nm.Add(pid, ep)
+}
+
+// Add adds endpoint ep to the provider-defined identifier (PID) pid.
+// It creates pid when pid does not exist. It does nothing when pid
+// already contains ep.
+func (nm *NetworkMap) Add(pid string, ep Endpoint) {
+	if nm.add(pid, ep) {
+		nm.changed()
+	}
+}
+
+func (nm *NetworkMap) add(pid string, ep Endpoint) bool {
+	if nm.Map == nil {
+		nm.Map = make(map[string]EndpointAddrGroup)
+	}
+	eag, ok := nm.Map[pid]
+	if !ok {
+		eag = make(EndpointAddrGroup)
 		nm.Map[pid] = eag
+	}
+	if indexOfEndpoint(eag[ep.Network()], ep) >= 0 {
+		return false
+	}
+	eag[ep.Network()] = append(eag[ep.Network()], ep)
+	return true
+}
+
+// Remove removes endpoint ep from the provider-defined identifier
+// (PID) pid. The PID remains even if it becomes empty.
+func (nm *NetworkMap) Remove(pid string, ep Endpoint) error {
+	if err := nm.remove(pid, ep); err != nil {
+		return err
+	}
+	nm.changed()
+	return nil
+}
+
+func (nm *NetworkMap) remove(pid string, ep Endpoint) error {
+	eag, ok := nm.Map[pid]
+	if !ok {
+		return errUnknownPID
+	}
+	eps := eag[ep.Network()]
+	i := indexOfEndpoint(eps, ep)
+	if i < 0 {
+		return errUnknownAddress
+	}
+	eps = append(eps[:i:i], eps[i+1:]...)
+	if len(eps) == 0 {
+		delete(eag, ep.Network())
 	} else {
-		if eps, ok := eag[ep.Network()]; !ok {
-			eag[ep.Network()] = []Endpoint{ep}
-		} else {
-			eps = append(eps, ep)
-			eag[ep.Network()] = eps
+		eag[ep.Network()] = eps
+	}
+	return nil
+}
+
+// Move moves endpoint ep from the provider-defined identifier (PID)
+// from to the PID to. It creates to when to does not exist.
+func (nm *NetworkMap) Move(ep Endpoint, from, to string) error {
+	if from == to {
+		if _, ok := nm.Map[from]; !ok {
+			return errUnknownPID
+		}
+		if indexOfEndpoint(nm.Map[from][ep.Network()], ep) < 0 {
+			return errUnknownAddress
+		}
+		return nil
+	}
+	if err := nm.remove(from, ep); err != nil {
+		return err
+	}
+	nm.add(to, ep)
+	nm.changed()
+	return nil
+}
+
+// RenamePID renames the provider-defined identifier (PID) from to to.
+// It fails when to already exists.
+func (nm *NetworkMap) RenamePID(from, to string) error {
+	eag, ok := nm.Map[from]
+	if !ok {
+		return errUnknownPID
+	}
+	if from == to {
+		return nil
+	}
+	if _, ok := nm.Map[to]; ok {
+		return errDuplicatePID
+	}
+	delete(nm.Map, from)
+	nm.Map[to] = eag
+	nm.changed()
+	return nil
+}
+
+// DeletePID deletes the provider-defined identifier (PID) pid and
+// its endpoints.
+func (nm *NetworkMap) DeletePID(pid string) error {
+	if _, ok := nm.Map[pid]; !ok {
+		return errUnknownPID
+	}
+	delete(nm.Map, pid)
+	nm.changed()
+	return nil
+}
+
+// ReplaceGroup replaces the endpoints of the provider-defined
+// identifier (PID) pid with eag. It creates pid when pid does not
+// exist.
+func (nm *NetworkMap) ReplaceGroup(pid string, eag EndpointAddrGroup) {
+	if nm.Map == nil {
+		nm.Map = make(map[string]EndpointAddrGroup)
+	}
+	neag := make(EndpointAddrGroup)
+	for typ, eps := range eag {
+		neag[typ] = append([]Endpoint(nil), eps...)
+	}
+	nm.Map[pid] = neag
+	nm.changed()
+}
+
+// changed invalidates the lookup index and replaces the version tag
+// with a new random one. Deriving the version tag from the content
+// here would cost a canonical encoding per mutation.
+func (nm *NetworkMap) changed() {
+	nm.index.Store((*pidIndex)(nil))
+	nm.VersionTag = newVersionTag()
+}
+
+func indexOfEndpoint(eps []Endpoint, ep Endpoint) int {
+	for i := range eps {
+		if eps[i].TypedString() == ep.TypedString() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Lookup returns the provider-defined identifier (PID) for endpoint
+// ep. IP endpoints are resolved by longest-prefix match, and other
+// endpoints by exact match. Changes made to the Map field directly
+// are not reflected until one of the mutation methods is called.
+// Lookup is safe for concurrent use unless nm is being modified.
+func (nm *NetworkMap) Lookup(ep Endpoint) (string, bool) {
+	idx, _ := nm.index.Load().(*pidIndex)
+	if idx == nil {
+		idx = newPIDIndex(nm.Map)
+		nm.index.Store(idx)
+	}
+	return idx.lookup(ep)
+}
+
+// Aggregate aggregates the IP address prefixes of nm in place. It
+// removes prefixes whose nearest covering prefix belongs to the same
+// PID, and merges sibling prefixes of the same PID into their
+// supernet unless another PID has the supernet. Unlike the Aggregate
+// method of EndpointAddrGroup, it takes the prefixes of all PIDs into
+// account, so longest-prefix match selects the same PID for any
+// address before and after aggregation. VersionTag is left unchanged.
+func (nm *NetworkMap) Aggregate() {
+	nm.index.Store((*pidIndex)(nil))
+	for _, typ := range []string{"ipv4", "ipv6"} {
+		pes := aggregatePrefixEntries(nm.prefixEntries(typ))
+		eps := make(map[string][]Endpoint)
+		for _, pe := range pes {
+			eps[pe.pid] = append(eps[pe.pid], pe.p.endpoint())
+		}
+		for pid, eag := range nm.Map {
+			if _, ok := eag[typ]; !ok {
+				continue
+			}
+			var others []Endpoint
+			for _, ep := range eag[typ] {
+				if _, ok := toIPPrefix(ep); !ok || ep.Network() != typ {
+					others = append(others, ep)
+				}
+			}
+			eag[typ] = append(eps[pid], sortEndpoints(others)...)
+		}
+	}
+	for _, eag := range nm.Map {
+		for typ, eps := range eag {
+			if typ != "ipv4" && typ != "ipv6" {
+				eag[typ] = sortEndpoints(eps)
+			}
+		}
+	}
+}
+
+// aggregatePrefixEntries aggregates the sorted prefix entries pes of
+// an address family until no more aggregation is possible.
+func aggregatePrefixEntries(pes []prefixEntry) []prefixEntry {
+	for {
+		n := len(pes)
+		pes = mergeSiblingEntries(removeRedundantEntries(pes))
+		if len(pes) == n {
+			return pes
+		}
+	}
+}
+
+// removeRedundantEntries removes the entries whose nearest covering
+// entry belongs to the same PID. Among the entries of the same
+// prefix, the first one wins longest-prefix match.
+func removeRedundantEntries(pes []prefixEntry) []prefixEntry {
+	var rs []prefixEntry
+	var stack []prefixEntry
+	for _, pe := range pes {
+		for len(stack) > 0 && !stack[len(stack)-1].p.contains(pe.p) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.pid == pe.pid {
+				continue
+			}
+			if top.p.equal(pe.p) {
+				rs = append(rs, pe) // ambiguous duplicate
+				continue
+			}
+		}
+		rs = append(rs, pe)
+		stack = append(stack, pe)
+	}
+	return rs
+}
+
+// mergeSiblingEntries merges sibling entries of the same PID into
+// their supernet when no entry has the supernet.
+func mergeSiblingEntries(pes []prefixEntry) []prefixEntry {
+	key := func(p ipPrefix) string { return string(p.ip) + string(rune(p.len)) }
+	count := make(map[string]int)
+	pids := make(map[string]string)
+	for _, pe := range pes {
+		count[key(pe.p)]++
+		pids[key(pe.p)] = pe.pid
+	}
+	merged := make(map[string]bool)
+	var rs []prefixEntry
+	for _, pe := range pes {
+		k := key(pe.p)
+		if merged[k] {
+			continue
+		}
+		if pe.p.len > 0 && count[k] == 1 && pe.p.parent().ip.Equal(pe.p.ip) {
+			upper := ipPrefix{ip: append(net.IP(nil), pe.p.ip...), len: pe.p.len}
+			upper.ip[(pe.p.len-1)/8] |= 0x80 >> uint((pe.p.len-1)%8)
+			uk, parent := key(upper), pe.p.parent()
+			if count[uk] == 1 && pids[uk] == pe.pid && count[key(parent)] == 0 {
+				merged[uk] = true
+				rs = append(rs, prefixEntry{p: parent, pid: pe.pid})
+				continue
+			}
+		}
+		rs = append(rs, pe)
+	}
+	sort.Sort(byPrefix(rs))
+	return rs
+}
+
+// A pidIndex represents a lookup index from endpoints to PIDs.
+type pidIndex struct {
+	prefixes map[int]map[string]string // prefix length to masked address to pid
+	others   map[string]string         // typed address to pid
+}
+
+func newPIDIndex(m map[string]EndpointAddrGroup) *pidIndex {
+	idx := &pidIndex{prefixes: make(map[int]map[string]string), others: make(map[string]string)}
+	pids := make([]string, 0, len(m))
+	for pid := range m {
+		pids = append(pids, pid)
+	}
+	sort.Strings(pids)
+	for _, pid := range pids {
+		for _, eps := range m[pid] {
+			for _, ep := range eps {
+				idx.add(pid, ep)
+			}
+		}
+	}
+	return idx
+}
+
+func (idx *pidIndex) add(pid string, ep Endpoint) {
+	p, ok := toIPPrefix(ep)
+	if !ok {
+		if _, ok := idx.others[ep.TypedString()]; !ok {
+			idx.others[ep.TypedString()] = pid
+		}
+		return
+	}
+	key := p.bits()<<8 | p.len
+	m, ok := idx.prefixes[key]
+	if !ok {
+		m = make(map[string]string)
+		idx.prefixes[key] = m
+	}
+	if _, ok := m[string(p.ip)]; !ok {
+		m[string(p.ip)] = pid
+	}
+}
+
+func (idx *pidIndex) lookup(ep Endpoint) (string, bool) {
+	p, ok := toIPPrefix(ep)
+	if !ok {
+		pid, ok := idx.others[ep.TypedString()]
+		return pid, ok
+	}
+	for l := p.len; l >= 0; l-- {
+		m, ok := idx.prefixes[p.bits()<<8|l]
+		if !ok {
+			continue
+		}
+		if pid, ok := m[string(p.ip.Mask(net.CIDRMask(l, p.bits())))]; ok {
+			return pid, true
 		}
-		nm.Map[pid] = eag
 	}
+	return "", false
 }
 
 // A ReqFilteredNetworkMap represents input parameters for the
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -74,3 +75,167 @@ func TestNetworkMap(t *testing.T) {
 		t.Fatalf("got %v; expected %v", len(eps), 1)
 	}
 }
+
+func mustParseEndpoint(t *testing.T, typ, addr string) Endpoint {
+	ep, err := ParseEndpoint(typ, addr)
+	if err != nil {
+		t.Fatalf("ParseEndpoint(%q, %q) failed: %v", typ, addr, err)
+	}
+	return ep
+}
+
+func lookupNetworkMap(t *testing.T, nm *NetworkMap, typ, addr, pid string) {
+	got, ok := nm.Lookup(mustParseEndpoint(t, typ, addr))
+	if !ok && pid != "" || got != pid {
+		t.Fatalf("Lookup(%q) = %q, %v; expected %q", addr, got, ok, pid)
+	}
+}
+
+func TestNetworkMapLookupConcurrent(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	decodeTestdata(t, "testdata/resource-networkmap.js", &Resource{Data: nm})
+	ep := mustParseEndpoint(t, "ipv4", "198.51.100.1")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if pid, ok := nm.Lookup(ep); !ok || pid != "pid1" {
+				t.Errorf("got %v, %v; expected %v", pid, ok, "pid1")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNetworkMapLifecycle(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	decodeTestdata(t, "testdata/resource-networkmap.js", &Resource{Data: nm})
+	lookupNetworkMap(t, nm, "ipv4", "198.51.100.200", "pid2")
+	lookupNetworkMap(t, nm, "ipv4", "198.51.100.1", "pid1")
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "pid3")
+	lookupNetworkMap(t, nm, "ipv6", "2001:db8::1", "pid3")
+
+	vtag := nm.VersionTag
+	ep := mustParseEndpoint(t, "ipv4", "203.0.113.0/24")
+	nm.Add("pid4", ep)
+	if nm.VersionTag == vtag {
+		t.Fatalf("Add did not bump version tag %v", vtag)
+	}
+	vtag = nm.VersionTag
+	covered := mustParseEndpoint(t, "ipv4", "203.0.113.128/25")
+	nm.Add("pid4", covered)
+	if nm.VersionTag == vtag {
+		t.Fatalf("Add of covered endpoint did not bump version tag %v", vtag)
+	}
+	vtag = nm.VersionTag
+	if err := nm.Remove("pid4", covered); err != nil || nm.VersionTag == vtag {
+		t.Fatalf("Remove did not bump version tag %v: %v", vtag, err)
+	}
+	vtag = nm.VersionTag
+	nm.Add("pid4", ep)
+	if nm.VersionTag != vtag {
+		t.Fatalf("Add of existing endpoint bumped version tag %v", vtag)
+	}
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "pid4")
+
+	if err := nm.Move(ep, "pid4", "pid1"); err != nil {
+		t.Fatalf("NetworkMap.Move failed: %v", err)
+	}
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "pid1")
+	if eps := nm.Endpoints("pid4", ""); len(eps) != 0 {
+		t.Fatalf("got %v; expected none", eps)
+	}
+	if err := nm.Move(ep, "pid4", "pid1"); err == nil {
+		t.Fatalf("NetworkMap.Move succeeded for missing endpoint")
+	}
+
+	if err := nm.RenamePID("pid1", "pid2"); err == nil {
+		t.Fatalf("NetworkMap.RenamePID succeeded for existing pid")
+	}
+	if err := nm.RenamePID("pid1", "pid5"); err != nil {
+		t.Fatalf("NetworkMap.RenamePID failed: %v", err)
+	}
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "pid5")
+
+	if err := nm.Remove("pid5", ep); err != nil {
+		t.Fatalf("NetworkMap.Remove failed: %v", err)
+	}
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "pid3")
+
+	if err := nm.DeletePID("pid3"); err != nil {
+		t.Fatalf("NetworkMap.DeletePID failed: %v", err)
+	}
+	if err := nm.DeletePID("pid3"); err == nil {
+		t.Fatalf("NetworkMap.DeletePID succeeded for missing pid")
+	}
+	lookupNetworkMap(t, nm, "ipv4", "203.0.113.1", "")
+
+	eag := EndpointAddrGroup{"ipv6": []Endpoint{mustParseEndpoint(t, "ipv6", "2001:db8::/32")}}
+	nm.ReplaceGroup("pid2", eag)
+	lookupNetworkMap(t, nm, "ipv4", "198.51.100.1", "pid5")
+	lookupNetworkMap(t, nm, "ipv4", "198.51.100.200", "")
+	lookupNetworkMap(t, nm, "ipv6", "2001:db8::1", "pid2")
+}
+
+var networkMapAggregateTests = []struct {
+	pid  string
+	net  string
+	addr string
+}{
+	{"pid1", "ipv4", "10.0.0.0/8"},
+	{"pid1", "ipv4", "10.1.1.0/24"},
+	{"pid1", "ipv4", "10.2.0.0/24"},
+	{"pid1", "ipv4", "10.4.2.0/24"},
+	{"pid1", "ipv4", "10.4.3.0/24"},
+	{"pid2", "ipv4", "10.1.0.0/16"},
+	{"pid2", "ipv4", "10.4.0.0/16"},
+	{"pid2", "ipv4", "10.5.0.0/24"},
+	{"pid2", "ipv4", "10.5.1.0/24"},
+	{"pid3", "ipv4", "10.5.0.0/23"},
+	{"pid3", "ipv6", "2001:db8::/33"},
+	{"pid3", "ipv6", "2001:db8:8000::/33"},
+	{"pid3", "mac-48", "01-23-45-67-89-ab"},
+}
+
+func TestNetworkMapAggregate(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	for _, tt := range networkMapAggregateTests {
+		eag, ok := nm.Map[tt.pid]
+		if !ok {
+			eag = make(EndpointAddrGroup)
+			nm.Map[tt.pid] = eag
+		}
+		eag[tt.net] = append(eag[tt.net], mustParseEndpoint(t, tt.net, tt.addr))
+	}
+	addrs := []string{"10.0.0.1", "10.1.0.1", "10.1.1.1", "10.2.0.1", "10.4.0.1", "10.4.2.1", "10.4.3.1", "10.5.0.1", "10.5.1.1", "11.0.0.1"}
+	pids := make([]string, len(addrs))
+	for i, addr := range addrs {
+		pids[i], _ = nm.Lookup(mustParseEndpoint(t, "ipv4", addr))
+	}
+	nm.Aggregate()
+	for pid, eps := range map[string][]string{
+		"pid1": {"10.0.0.0/8", "10.1.1.0/24", "10.4.2.0/23"},
+		"pid2": {"10.1.0.0/16", "10.4.0.0/16", "10.5.0.0/24", "10.5.1.0/24"},
+		"pid3": {"10.5.0.0/23"},
+	} {
+		if got := nm.Endpoints(pid, "ipv4"); len(got) != len(eps) {
+			t.Fatalf("%s: got %v; expected %v", pid, got, eps)
+		} else {
+			for i := range eps {
+				if got[i].String() != eps[i] {
+					t.Fatalf("%s: got %v; expected %v", pid, got, eps)
+				}
+			}
+		}
+	}
+	if eps := nm.Endpoints("pid3", "ipv6"); len(eps) != 1 || eps[0].String() != "2001:db8::/32" {
+		t.Fatalf("got %v; expected %v", eps, "2001:db8::/32")
+	}
+	if eps := nm.Endpoints("pid3", "mac-48"); len(eps) != 1 {
+		t.Fatalf("got %v; expected a mac-48 endpoint", eps)
+	}
+	for i, addr := range addrs {
+		lookupNetworkMap(t, nm, "ipv4", addr, pids[i])
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	errInvalidCostMode = errors.New("invalid cost mode")
+)
+
+// A RankScope represents a scope of ordinal rankings.
+type RankScope int
+
+const (
+	RankPerSource RankScope = iota // rank costs within each source
+	RankGlobal                     // rank costs across the entire map
+)
+
+// A TieBreak represents a rule for ranking equal costs.
+type TieBreak int
+
+const (
+	TieBreakDense       TieBreak = iota // equal costs share a rank, next rank follows immediately; 1, 1, 2
+	TieBreakCompetition                 // equal costs share a rank, next rank skips; 1, 1, 3
+	TieBreakName                        // equal costs are ranked in order of source and destination names; 1, 2, 3
+)
+
+type rankEntry struct {
+	src, dst string
+	cost     float64
+	rank     int
+}
+
+type byCost []*rankEntry
+
+func (s byCost) Len() int      { return len(s) }
+func (s byCost) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCost) Less(i, j int) bool {
+	if s[i].cost != s[j].cost {
+		return s[i].cost < s[j].cost
+	}
+	if s[i].src != s[j].src {
+		return s[i].src < s[j].src
+	}
+	return s[i].dst < s[j].dst
+}
+
+func rank(es []*rankEntry, tb TieBreak) {
+	sort.Sort(byCost(es))
+	r := 0
+	for i, e := range es {
+		switch {
+		case i > 0 && e.cost == es[i-1].cost && tb != TieBreakName:
+			e.rank = es[i-1].rank
+			continue
+		case tb == TieBreakDense:
+			r++
+		default:
+			r = i + 1
+		}
+		e.rank = r
+	}
+}
+
+func rankAll(es []*rankEntry, scope RankScope, tb TieBreak) {
+	if scope == RankGlobal {
+		rank(es, tb)
+		return
+	}
+	srcs := make(map[string][]*rankEntry)
+	for _, e := range es {
+		srcs[e.src] = append(srcs[e.src], e)
+	}
+	for _, es := range srcs {
+		rank(es, tb)
+	}
+}
+
+func numericalCost(v interface{}) (float64, bool) {
+	var f float64
+	switch v := v.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+func isOrdinalCost(v interface{}) bool {
+	f, ok := numericalCost(v)
+	return ok && f >= 1 && f == math.Trunc(f)
+}
+
+// Ordinal returns a copy of the numerical cost map cm in which each
+// cost is replaced with its ordinal ranking. Rankings start at 1 for
+// the lowest cost within scope, and equal costs are ranked by tb.
+func (cm *CostMap) Ordinal(scope RankScope, tb TieBreak) (*CostMap, error) {
+	if cm.CostType.CostMode != CostModeNumerical {
+		return nil, errInvalidCostMode
+	}
+	var es []*rankEntry
+	for src, dcs := range cm.Map {
+		for dst, c := range dcs {
+			if _, ok := numericalCost(c); !ok {
+				return nil, fmt.Errorf("invalid cost %v from %s to %s", c, src, dst)
+			}
+			es = append(es, &rankEntry{src: src, dst: dst, cost: c})
+		}
+	}
+	rankAll(es, scope, tb)
+	ocm := &CostMap{CostType: cm.CostType, VersionTag: cm.VersionTag, Map: make(map[string]DstCosts)}
+	ocm.CostType.CostMode = CostModeOrdinal
+	for _, e := range es {
+		dcs, ok := ocm.Map[e.src]
+		if !ok {
+			dcs = make(DstCosts)
+			ocm.Map[e.src] = dcs
+		}
+		dcs[e.dst] = float64(e.rank)
+	}
+	return ocm, nil
+}
+
+// ValidateOrdinal reports whether the ordinal cost map cm contains
+// only positive integer costs.
+func (cm *CostMap) ValidateOrdinal() error {
+	if cm.CostType.CostMode != CostModeOrdinal {
+		return errInvalidCostMode
+	}
+	for src, dcs := range cm.Map {
+		for dst, c := range dcs {
+			if !isOrdinalCost(c) {
+				return fmt.Errorf("invalid ordinal cost %v from %s to %s", c, src, dst)
+			}
+		}
+	}
+	return nil
+}
+
+// Ordinal returns a copy of the numerical endpoint cost map ecm in
+// which each cost is replaced with its ordinal ranking. Rankings
+// start at 1 for the lowest cost within scope, and equal costs are
+// ranked by tb.
+func (ecm *EndpointCostMap) Ordinal(scope RankScope, tb TieBreak) (*EndpointCostMap, error) {
+	if ecm.CostType.CostMode != CostModeNumerical {
+		return nil, errInvalidCostMode
+	}
+	var es []*rankEntry
+	for src, dcs := range ecm.Map {
+		for dst, v := range dcs {
+			c, ok := numericalCost(v)
+			if !ok {
+				return nil, fmt.Errorf("invalid cost %v from %s to %s", v, src, dst)
+			}
+			es = append(es, &rankEntry{src: src, dst: dst, cost: c})
+		}
+	}
+	rankAll(es, scope, tb)
+	oecm := &EndpointCostMap{CostType: ecm.CostType, Map: make(map[string]EndpointDstCosts)}
+	oecm.CostType.CostMode = CostModeOrdinal
+	for _, e := range es {
+		dcs, ok := oecm.Map[e.src]
+		if !ok {
+			dcs = make(EndpointDstCosts)
+			oecm.Map[e.src] = dcs
+		}
+		dcs[e.dst] = float64(e.rank)
+	}
+	return oecm, nil
+}
+
+// ValidateOrdinal reports whether the ordinal endpoint cost map ecm
+// contains only positive integer costs.
+func (ecm *EndpointCostMap) ValidateOrdinal() error {
+	if ecm.CostType.CostMode != CostModeOrdinal {
+		return errInvalidCostMode
+	}
+	for src, dcs := range ecm.Map {
+		for dst, v := range dcs {
+			if !isOrdinalCost(v) {
+				return fmt.Errorf("invalid ordinal cost %v from %s to %s", v, src, dst)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var costMapOrdinalTests = []struct {
+	scope RankScope
+	tb    TieBreak
+	out   map[string]DstCosts
+}{
+	{
+		RankPerSource, TieBreakDense,
+		map[string]DstCosts{
+			"pid1": {"pid1": 1, "pid2": 2, "pid3": 3},
+			"pid2": {"pid1": 2, "pid2": 1, "pid3": 3},
+			"pid3": {"pid1": 2, "pid2": 1},
+		},
+	},
+	{
+		RankGlobal, TieBreakDense,
+		map[string]DstCosts{
+			"pid1": {"pid1": 1, "pid2": 2, "pid3": 3},
+			"pid2": {"pid1": 2, "pid2": 1, "pid3": 4},
+			"pid3": {"pid1": 5, "pid2": 4},
+		},
+	},
+	{
+		RankGlobal, TieBreakCompetition,
+		map[string]DstCosts{
+			"pid1": {"pid1": 1, "pid2": 3, "pid3": 5},
+			"pid2": {"pid1": 3, "pid2": 1, "pid3": 6},
+			"pid3": {"pid1": 8, "pid2": 6},
+		},
+	},
+	{
+		RankGlobal, TieBreakName,
+		map[string]DstCosts{
+			"pid1": {"pid1": 1, "pid2": 3, "pid3": 5},
+			"pid2": {"pid1": 4, "pid2": 2, "pid3": 6},
+			"pid3": {"pid1": 8, "pid2": 7},
+		},
+	},
+}
+
+func TestCostMapOrdinal(t *testing.T) {
+	f, err := os.Open("testdata/costmap.js")
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	cm := CostMap{}
+	if err := json.NewDecoder(f).Decode(&cm); err != nil {
+		t.Fatalf("json.Decoder.Decode failed: %v", err)
+	}
+	if err := cm.ValidateOrdinal(); err == nil {
+		t.Fatalf("CostMap.ValidateOrdinal succeeded for %v cost mode", cm.CostType.CostMode)
+	}
+	for _, tt := range costMapOrdinalTests {
+		ocm, err := cm.Ordinal(tt.scope, tt.tb)
+		if err != nil {
+			t.Fatalf("CostMap.Ordinal failed: %v", err)
+		}
+		if ocm.CostType.CostMode != CostModeOrdinal {
+			t.Fatalf("got %v; expected %v", ocm.CostType.CostMode, CostModeOrdinal)
+		}
+		if !reflect.DeepEqual(ocm.Map, tt.out) {
+			t.Fatalf("%v, %v: got %v; expected %v", tt.scope, tt.tb, ocm.Map, tt.out)
+		}
+		if err := ocm.ValidateOrdinal(); err != nil {
+			t.Fatalf("CostMap.ValidateOrdinal failed: %v", err)
+		}
+	}
+}
+
+func TestEndpointCostMapOrdinal(t *testing.T) {
+	ecm := EndpointCostMap{
+		CostType: CostType{CostMetric: "routingcost", CostMode: CostModeNumerical},
+		Map: map[string]EndpointDstCosts{
+			"ipv4:192.0.2.2": {"ipv4:192.0.2.89": 1.5, "ipv4:198.51.100.34": 5, "ipv4:203.0.113.45": 1.5},
+		},
+	}
+	oecm, err := ecm.Ordinal(RankPerSource, TieBreakCompetition)
+	if err != nil {
+		t.Fatalf("EndpointCostMap.Ordinal failed: %v", err)
+	}
+	out := map[string]EndpointDstCosts{
+		"ipv4:192.0.2.2": {"ipv4:192.0.2.89": 1.0, "ipv4:198.51.100.34": 3.0, "ipv4:203.0.113.45": 1.0},
+	}
+	if !reflect.DeepEqual(oecm.Map, out) {
+		t.Fatalf("got %v; expected %v", oecm.Map, out)
+	}
+	if err := oecm.ValidateOrdinal(); err != nil {
+		t.Fatalf("EndpointCostMap.ValidateOrdinal failed: %v", err)
+	}
+	for _, c := range []interface{}{0.0, -1.0, 1.5, "1"} {
+		oecm.Map["ipv4:192.0.2.2"]["ipv4:192.0.2.89"] = c
+		if err := oecm.ValidateOrdinal(); err == nil {
+			t.Fatalf("EndpointCostMap.ValidateOrdinal succeeded for %v", c)
+		}
+	}
+}
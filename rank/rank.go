@@ -0,0 +1,241 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package rank implements peer and cache ranking based on the
+// Application-Layer Traffic Optimization (ALTO) costs.
+//
+// A Ranker orders candidate endpoints for a source endpoint by the
+// costs taken from a CostSource, which is either a pair of local
+// network map and cost map or an ALTO endpoint cost service, and
+// a Strategy deciding how strictly the costs are followed.
+package rank
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/client"
+)
+
+// A Candidate represents a ranked candidate endpoint.
+type Candidate struct {
+	Endpoint alto.Endpoint
+	Cost     float64 // cost from source; valid only when Known is true
+	Known    bool    // whether the cost is known
+}
+
+// A CostSource represents a source of endpoint costs.
+type CostSource interface {
+	// EndpointCosts returns the costs from src to dsts keyed by
+	// the typed address of each destination. Destinations of
+	// unknown cost are omitted.
+	EndpointCosts(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error)
+}
+
+// A MapSource represents a cost source backed by a local network map
+// and cost map. Endpoints are mapped to PIDs with the network map,
+// and the costs between PIDs are taken from the cost map. The maps
+// must not be modified while in use; maps taken from an alto.Store
+// snapshot are safe for concurrent use.
+type MapSource struct {
+	NetworkMap *alto.NetworkMap
+	CostMap    *alto.CostMap
+}
+
+// EndpointCosts implements the EndpointCosts method of CostSource
+// interface.
+func (ms *MapSource) EndpointCosts(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error) {
+	costs := make(map[string]float64)
+	spid, ok := ms.NetworkMap.Lookup(src)
+	if !ok {
+		return costs, nil
+	}
+	dcs := ms.CostMap.Map[spid]
+	for _, dst := range dsts {
+		dpid, ok := ms.NetworkMap.Lookup(dst)
+		if !ok {
+			continue
+		}
+		if c, ok := dcs[dpid]; ok {
+			costs[dst.TypedString()] = c
+		}
+	}
+	return costs, nil
+}
+
+// A ServiceSource represents a cost source backed by an ALTO endpoint
+// cost service.
+type ServiceSource struct {
+	Client   *client.Client
+	URI      string        // uri of endpoint cost service
+	CostType alto.CostType // cost type to query
+}
+
+// EndpointCosts implements the EndpointCosts method of CostSource
+// interface.
+func (ss *ServiceSource) EndpointCosts(ctx context.Context, src alto.Endpoint, dsts []alto.Endpoint) (map[string]float64, error) {
+	var params alto.ReqEndpointCostMap
+	params.CostType = ss.CostType
+	params.Endpoints.Srcs = []alto.Endpoint{src}
+	params.Endpoints.Dsts = dsts
+	ecm, err := ss.Client.EndpointCost(ctx, ss.URI, &params)
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[string]float64)
+	for _, dcs := range ecm.Map {
+		for dst, v := range dcs {
+			if c, ok := v.(float64); ok {
+				costs[dst] = c
+			}
+		}
+	}
+	return costs, nil
+}
+
+// A Strategy represents a strategy for ordering candidates.
+type Strategy interface {
+	// Order orders candidates cs in place. It may use rnd as a
+	// source of randomness.
+	Order(cs []Candidate, rnd *rand.Rand)
+}
+
+// A MinCost represents a strategy that orders candidates strictly by
+// cost. Candidates of unknown cost come last, and candidates of equal
+// cost keep their input order.
+type MinCost struct{}
+
+// Order implements the Order method of Strategy interface.
+func (MinCost) Order(cs []Candidate, rnd *rand.Rand) {
+	sort.Stable(byCost(cs))
+}
+
+type byCost []Candidate
+
+func (s byCost) Len() int      { return len(s) }
+func (s byCost) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCost) Less(i, j int) bool {
+	if s[i].Known != s[j].Known {
+		return s[i].Known
+	}
+	return s[i].Known && s[i].Cost < s[j].Cost
+}
+
+// A WeightedRandom represents a strategy that draws candidates at
+// random with weights inversely proportional to their costs.
+// Candidates of unknown cost are weighted as the most costly known
+// candidate.
+type WeightedRandom struct{}
+
+// Order implements the Order method of Strategy interface.
+func (WeightedRandom) Order(cs []Candidate, rnd *rand.Rand) {
+	max := 0.0
+	for _, c := range cs {
+		if c.Known && c.Cost > max {
+			max = c.Cost
+		}
+	}
+	ws := make([]float64, len(cs))
+	for i, c := range cs {
+		ws[i] = weight(c, max)
+	}
+	for i := range cs {
+		sum := 0.0
+		for _, w := range ws[i:] {
+			sum += w
+		}
+		x, j := rnd.Float64()*sum, i
+		for ; j < len(cs)-1; j++ {
+			if x -= ws[j]; x < 0 {
+				break
+			}
+		}
+		cs[i], cs[j] = cs[j], cs[i]
+		ws[i], ws[j] = ws[j], ws[i]
+	}
+}
+
+func weight(c Candidate, max float64) float64 {
+	cost := c.Cost
+	if !c.Known {
+		cost = max
+	}
+	if cost <= 0 {
+		return 1 / math.SmallestNonzeroFloat32
+	}
+	return 1 / cost
+}
+
+// A LocalityShare represents a strategy that places the cheapest
+// candidates first and the others at random order, so that a fixed
+// share of selections stays local while the rest keeps diversity.
+type LocalityShare struct {
+	Share float64 // share of candidates ordered by cost, from 0 to 1
+}
+
+// Order implements the Order method of Strategy interface.
+func (ls LocalityShare) Order(cs []Candidate, rnd *rand.Rand) {
+	sort.Stable(byCost(cs))
+	n := int(math.Ceil(ls.Share * float64(len(cs))))
+	if n < 0 {
+		n = 0
+	}
+	if n > len(cs) {
+		n = len(cs)
+	}
+	tail := cs[n:]
+	rnd.Shuffle(len(tail), func(i, j int) { tail[i], tail[j] = tail[j], tail[i] })
+}
+
+// A Ranker represents a ranker of candidate endpoints.
+type Ranker struct {
+	Source   CostSource
+	Strategy Strategy // nil means MinCost
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewRanker returns a new ranker with cost source src and strategy
+// st.
+func NewRanker(src CostSource, st Strategy) *Ranker {
+	return &Ranker{Source: src, Strategy: st}
+}
+
+// Seed sets the seed of the random source used by the strategy.
+func (r *Ranker) Seed(seed int64) {
+	r.mu.Lock()
+	r.rnd = rand.New(rand.NewSource(seed))
+	r.mu.Unlock()
+}
+
+// Rank returns the candidate endpoints cands ordered for the source
+// endpoint src.
+func (r *Ranker) Rank(ctx context.Context, src alto.Endpoint, cands []alto.Endpoint) ([]Candidate, error) {
+	costs, err := r.Source.EndpointCosts(ctx, src, cands)
+	if err != nil {
+		return nil, err
+	}
+	cs := make([]Candidate, len(cands))
+	for i, ep := range cands {
+		c, ok := costs[ep.TypedString()]
+		cs[i] = Candidate{Endpoint: ep, Cost: c, Known: ok}
+	}
+	st := r.Strategy
+	if st == nil {
+		st = MinCost{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rnd == nil {
+		r.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	st.Order(cs, r.rnd)
+	return cs, nil
+}
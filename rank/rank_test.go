@@ -0,0 +1,162 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package rank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/mikioh/alto"
+	"github.com/mikioh/alto/client"
+)
+
+func decodeTestdata(t *testing.T, name string, v interface{}) {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("os.Open failed: %v", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		t.Fatalf("json.Decoder.Decode failed: %v", err)
+	}
+}
+
+func parseEndpoints(t *testing.T, ss ...string) []alto.Endpoint {
+	var eps []alto.Endpoint
+	for _, s := range ss {
+		typ := "ipv4"
+		if len(s) > 16 {
+			typ = "mac-48"
+		}
+		ep, err := alto.ParseEndpoint(typ, s)
+		if err != nil {
+			t.Fatalf("alto.ParseEndpoint failed: %v", err)
+		}
+		eps = append(eps, ep)
+	}
+	return eps
+}
+
+func newMapSource(t *testing.T) *MapSource {
+	nm := alto.NewResource("networkmap")
+	decodeTestdata(t, "../testdata/resource-networkmap.js", nm)
+	cm := alto.NewResource("costmap")
+	decodeTestdata(t, "../testdata/resource-costmap.js", cm)
+	return &MapSource{NetworkMap: nm.Data.(*alto.NetworkMap), CostMap: cm.Data.(*alto.CostMap)}
+}
+
+var rankCandidates = []string{"203.0.113.1", "01:23:45:67:89:ab", "198.51.100.200", "192.0.2.20"}
+
+func TestMinCost(t *testing.T) {
+	r := NewRanker(newMapSource(t), nil)
+	src, cands := parseEndpoints(t, "192.0.2.10")[0], parseEndpoints(t, rankCandidates...)
+	cs, err := r.Rank(context.Background(), src, cands)
+	if err != nil {
+		t.Fatalf("Ranker.Rank failed: %v", err)
+	}
+	expected := []string{"192.0.2.20", "198.51.100.200", "203.0.113.1", "01:23:45:67:89:ab"}
+	costs := []float64{1, 5, 10}
+	for i, c := range cs {
+		if c.Endpoint.String() != expected[i] {
+			t.Fatalf("#%d: got %v; expected %v", i, c.Endpoint, expected[i])
+		}
+		if i < len(costs) && (!c.Known || c.Cost != costs[i]) {
+			t.Fatalf("#%d: got %v, %v; expected %v", i, c.Cost, c.Known, costs[i])
+		}
+	}
+	if cs[3].Known {
+		t.Fatalf("got known cost %v for %v", cs[3].Cost, cs[3].Endpoint)
+	}
+}
+
+func TestWeightedRandom(t *testing.T) {
+	r := NewRanker(newMapSource(t), WeightedRandom{})
+	r.Seed(1)
+	src, cands := parseEndpoints(t, "192.0.2.10")[0], parseEndpoints(t, rankCandidates...)
+	firsts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		cs, err := r.Rank(context.Background(), src, cands)
+		if err != nil {
+			t.Fatalf("Ranker.Rank failed: %v", err)
+		}
+		if len(cs) != len(cands) {
+			t.Fatalf("got %v; expected %v candidates", len(cs), len(cands))
+		}
+		firsts[cs[0].Endpoint.String()]++
+	}
+	// The weights are 1, 1/5, 1/10 and 1/10.
+	if n := firsts["192.0.2.20"]; n < 600 || n > 800 {
+		t.Fatalf("got %v; expected about %v", firsts, 714)
+	}
+	if firsts["198.51.100.200"] < firsts["203.0.113.1"] {
+		t.Fatalf("got %v; expected cheaper candidate more often", firsts)
+	}
+}
+
+func TestLocalityShare(t *testing.T) {
+	r := NewRanker(newMapSource(t), LocalityShare{Share: 0.5})
+	r.Seed(1)
+	src, cands := parseEndpoints(t, "192.0.2.10")[0], parseEndpoints(t, rankCandidates...)
+	tails := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		cs, err := r.Rank(context.Background(), src, cands)
+		if err != nil {
+			t.Fatalf("Ranker.Rank failed: %v", err)
+		}
+		if cs[0].Endpoint.String() != "192.0.2.20" || cs[1].Endpoint.String() != "198.51.100.200" {
+			t.Fatalf("got %v; expected cheapest candidates first", cs)
+		}
+		tails[cs[2].Endpoint.String()]++
+	}
+	if len(tails) != 2 {
+		t.Fatalf("got %v; expected random tail", tails)
+	}
+}
+
+func TestServiceSource(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !alto.Negotiate(w, req, alto.MediaTypeEndpointCost, alto.MediaTypeEndpointCostParams) {
+			return
+		}
+		var params struct {
+			Endpoints struct {
+				Srcs []string `json:"srcs"`
+				Dsts []string `json:"dsts"`
+			} `json:"endpoints"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&params); err != nil || len(params.Endpoints.Srcs) != 1 {
+			alto.WriteError(w, http.StatusBadRequest, alto.ErrSyntax)
+			return
+		}
+		dcs := make(alto.EndpointDstCosts)
+		for i, dst := range params.Endpoints.Dsts {
+			dcs[dst] = float64(len(params.Endpoints.Dsts) - i)
+		}
+		r := alto.Resource{Meta: alto.Meta{}, Data: &alto.EndpointCostMap{
+			CostType: alto.CostType{CostMetric: "routingcost", CostMode: alto.CostModeNumerical},
+			Map:      map[string]alto.EndpointDstCosts{params.Endpoints.Srcs[0]: dcs},
+		}}
+		w.Header().Set("Content-Type", alto.MediaTypeEndpointCost)
+		json.NewEncoder(w).Encode(&r)
+	}))
+	defer ts.Close()
+
+	ss := &ServiceSource{Client: client.New(), URI: ts.URL, CostType: alto.CostType{CostMetric: "routingcost", CostMode: alto.CostModeNumerical}}
+	r := NewRanker(ss, MinCost{})
+	src, cands := parseEndpoints(t, "192.0.2.10")[0], parseEndpoints(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	cs, err := r.Rank(context.Background(), src, cands)
+	if err != nil {
+		t.Fatalf("Ranker.Rank failed: %v", err)
+	}
+	for i, c := range cs {
+		if c.Endpoint != cands[len(cands)-1-i] || !c.Known {
+			t.Fatalf("#%d: got %+v; expected %v", i, c, cands[len(cands)-1-i])
+		}
+	}
+}
@@ -20,13 +20,18 @@ type Data interface {
 }
 
 // NewResource returns an information resource. Known information
-// resource types are "networkmap" and "costmap".
+// resource types are "networkmap", "costmap", "endpointcost" and
+// "endpointprop".
 func NewResource(typ string) *Resource {
 	switch typ {
 	case "networkmap":
 		return &Resource{Data: &NetworkMap{Map: make(map[string]EndpointAddrGroup)}}
 	case "costmap":
 		return &Resource{Data: &CostMap{Map: make(map[string]DstCosts)}}
+	case "endpointcost":
+		return &Resource{Data: &EndpointCostMap{Map: make(map[string]EndpointDstCosts)}}
+	case "endpointprop":
+		return &Resource{Data: &EndpointProperty{Map: make(map[string]EndpointProps)}}
 	default:
 		return &Resource{}
 	}
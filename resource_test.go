@@ -76,3 +76,27 @@ func TestDecodeEncodeResource(t *testing.T) {
 		}
 	}
 }
+
+var decodeMalformedTests = []struct {
+	typ, in string
+}{
+	{"costmap", `{"cost-type": "x", "map-vtag": "1", "map": {}}`},
+	{"costmap", `{"cost-type": {}, "map-vtag": "1", "map": []}`},
+	{"costmap", `[]`},
+	{"networkmap", `{"map-vtag": "1", "map": "x"}`},
+	{"networkmap", `{"map-vtag": "1", "map": {"pid1": {"ipv4": ["192.0.2.0/24"]}}, "x": 1, "map": 1}`},
+	{"networkmap", `"x"`},
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, tt := range decodeMalformedTests {
+		d := NewResource(tt.typ).Data
+		if err := json.Unmarshal([]byte(tt.in), d); err == nil {
+			t.Fatalf("%s: json.Unmarshal succeeded for %s", tt.typ, tt.in)
+		}
+	}
+	eag := make(EndpointAddrGroup)
+	if err := eag.UnmarshalJSON([]byte(`["192.0.2.0/24"]`)); err == nil {
+		t.Fatal("EndpointAddrGroup.UnmarshalJSON succeeded for array")
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package rtable
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Routes returns the unicast routes in all the kernel routing tables.
+// It falls back to the main routing table in /proc/net/route and
+// /proc/net/ipv6_route when netlink is unavailable.
+func Routes() ([]Route, error) {
+	rts, err := netlinkRoutes()
+	if err != nil {
+		return procRoutes()
+	}
+	return rts, nil
+}
+
+func netlinkRoutes() ([]Route, error) {
+	b, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(b)
+	if err != nil {
+		return nil, err
+	}
+	ifnames := make(map[int]string)
+	var rts []Route
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Header.Type == syscall.NLMSG_DONE {
+			break
+		}
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < syscall.SizeofRtMsg {
+			continue
+		}
+		rtm := (*syscall.RtMsg)(unsafe.Pointer(&m.Data[0]))
+		if rtm.Type != syscall.RTN_UNICAST {
+			continue
+		}
+		bits := 8 * net.IPv4len
+		if rtm.Family == syscall.AF_INET6 {
+			bits = 8 * net.IPv6len
+		} else if rtm.Family != syscall.AF_INET {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(m)
+		if err != nil {
+			return nil, err
+		}
+		rt := Route{Dst: &net.IPNet{IP: make(net.IP, bits/8), Mask: net.CIDRMask(int(rtm.Dst_len), bits)}, Table: int(rtm.Table)}
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case syscall.RTA_DST:
+				if len(a.Value) == bits/8 {
+					rt.Dst.IP = net.IP(a.Value)
+				}
+			case syscall.RTA_GATEWAY:
+				if len(a.Value) == bits/8 {
+					rt.Gateway = net.IP(a.Value)
+				}
+			case syscall.RTA_OIF:
+				if len(a.Value) == 4 {
+					index := int(binary.NativeEndian.Uint32(a.Value))
+					name, ok := ifnames[index]
+					if !ok {
+						if ifi, err := net.InterfaceByIndex(index); err == nil {
+							name = ifi.Name
+						}
+						ifnames[index] = name
+					}
+					rt.Interface = name
+				}
+			case syscall.RTA_TABLE:
+				if len(a.Value) == 4 {
+					rt.Table = int(binary.NativeEndian.Uint32(a.Value))
+				}
+			}
+		}
+		rts = append(rts, rt)
+	}
+	return rts, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package rtable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	rtfUp      = 0x0001
+	rtfGateway = 0x0002
+	rtfReject  = 0x0200
+)
+
+// procRoutes reads the routes of the main routing table from
+// /proc/net/route and /proc/net/ipv6_route.
+func procRoutes() ([]Route, error) {
+	var rts []Route
+	for _, p := range []struct {
+		name  string
+		parse func(io.Reader) ([]Route, error)
+	}{
+		{"/proc/net/route", parseProcRoute},
+		{"/proc/net/ipv6_route", parseProcIPv6Route},
+	} {
+		f, err := os.Open(p.name)
+		if err != nil {
+			if os.IsNotExist(err) && p.name == "/proc/net/ipv6_route" {
+				continue // IPv6 is disabled
+			}
+			return nil, err
+		}
+		rs, err := p.parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", p.name, err)
+		}
+		rts = append(rts, rs...)
+	}
+	return rts, nil
+}
+
+// parseProcRoute parses the content of /proc/net/route, in which
+// addresses are hexadecimal numbers in host byte order.
+func parseProcRoute(r io.Reader) ([]Route, error) {
+	var rts []Route
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		fs := strings.Fields(s.Text())
+		if n == 1 || len(fs) == 0 {
+			continue // header
+		}
+		if len(fs) < 8 {
+			return nil, fmt.Errorf("line %d: too few fields", n)
+		}
+		flags, err := strconv.ParseUint(fs[3], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid flags %s", n, fs[3])
+		}
+		if flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		var ips [3]net.IP
+		for i, f := range []string{fs[1], fs[2], fs[7]} {
+			v, err := strconv.ParseUint(f, 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid address %s", n, f)
+			}
+			ips[i] = make(net.IP, net.IPv4len)
+			binary.NativeEndian.PutUint32(ips[i], uint32(v))
+		}
+		rt := Route{Dst: &net.IPNet{IP: ips[0], Mask: net.IPMask(ips[2])}, Interface: fs[0], Table: TableMain}
+		if flags&rtfGateway != 0 {
+			rt.Gateway = ips[1]
+		}
+		rts = append(rts, rt)
+	}
+	return rts, s.Err()
+}
+
+// parseProcIPv6Route parses the content of /proc/net/ipv6_route, in
+// which addresses are hexadecimal strings in network byte order.
+func parseProcIPv6Route(r io.Reader) ([]Route, error) {
+	var rts []Route
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		fs := strings.Fields(s.Text())
+		if len(fs) == 0 {
+			continue
+		}
+		if len(fs) < 10 {
+			return nil, fmt.Errorf("line %d: too few fields", n)
+		}
+		dst, err1 := hex.DecodeString(fs[0])
+		l, err2 := strconv.ParseUint(fs[1], 16, 8)
+		gw, err3 := hex.DecodeString(fs[4])
+		flags, err4 := strconv.ParseUint(fs[8], 16, 32)
+		for _, err := range []error{err1, err2, err3, err4} {
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+		}
+		if len(dst) != net.IPv6len || len(gw) != net.IPv6len || l > 8*net.IPv6len {
+			return nil, fmt.Errorf("line %d: invalid route", n)
+		}
+		if flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		rt := Route{Dst: &net.IPNet{IP: net.IP(dst), Mask: net.CIDRMask(int(l), 8*net.IPv6len)}, Interface: fs[9], Table: TableMain}
+		if flags&rtfGateway != 0 && !net.IP(gw).IsUnspecified() {
+			rt.Gateway = net.IP(gw)
+		}
+		rts = append(rts, rt)
+	}
+	return rts, s.Err()
+}
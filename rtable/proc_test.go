@@ -0,0 +1,61 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package rtable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// hostHex returns the IPv4 address s as a hexadecimal number in host
+// byte order.
+func hostHex(s string) string {
+	return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(net.ParseIP(s).To4()))
+}
+
+func TestParseProcRoute(t *testing.T) {
+	s := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		fmt.Sprintf("eth0\t%s\t%s\t0003\t0\t0\t100\t%s\t0\t0\t0\n", hostHex("0.0.0.0"), hostHex("192.0.2.1"), hostHex("0.0.0.0")) +
+		fmt.Sprintf("eth0\t%s\t%s\t0001\t0\t0\t100\t%s\t0\t0\t0\n", hostHex("192.0.2.0"), hostHex("0.0.0.0"), hostHex("255.255.255.0")) +
+		fmt.Sprintf("eth1\t%s\t%s\t0201\t0\t0\t100\t%s\t0\t0\t0\n", hostHex("198.51.100.0"), hostHex("0.0.0.0"), hostHex("255.255.255.0"))
+	rts, err := parseProcRoute(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("parseProcRoute failed: %v", err)
+	}
+	if len(rts) != 2 {
+		t.Fatalf("got %v; expected 2 routes", rts)
+	}
+	if rts[0].Dst.String() != "0.0.0.0/0" || !rts[0].Gateway.Equal(net.ParseIP("192.0.2.1")) || rts[0].Interface != "eth0" || rts[0].Table != TableMain {
+		t.Fatalf("got %+v; expected default route via %v", rts[0], "192.0.2.1")
+	}
+	if rts[1].Dst.String() != "192.0.2.0/24" || rts[1].Gateway != nil {
+		t.Fatalf("got %+v; expected connected route to %v", rts[1], "192.0.2.0/24")
+	}
+}
+
+func TestParseProcIPv6Route(t *testing.T) {
+	s := "20010db8000000000000000000000000 20 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0\n" +
+		"00000000000000000000000000000000 00 00000000000000000000000000000000 00 20010db8000000000000000000000001 00000400 00000001 00000000 00000003     eth0\n" +
+		"20010db8000100000000000000000000 30 00000000000000000000000000000000 00 00000000000000000000000000000000 00000400 00000001 00000000 00200200     lo\n"
+	rts, err := parseProcIPv6Route(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("parseProcIPv6Route failed: %v", err)
+	}
+	if len(rts) != 2 {
+		t.Fatalf("got %v; expected 2 routes", rts)
+	}
+	if rts[0].Dst.String() != "2001:db8::/32" || rts[0].Gateway != nil {
+		t.Fatalf("got %+v; expected connected route to %v", rts[0], "2001:db8::/32")
+	}
+	if rts[1].Dst.String() != "::/0" || !rts[1].Gateway.Equal(net.ParseIP("2001:db8::1")) {
+		t.Fatalf("got %+v; expected default route via %v", rts[1], "2001:db8::1")
+	}
+	if _, err := parseProcIPv6Route(strings.NewReader("2001 20 eth0\n")); err == nil {
+		t.Fatal("got nil; expected an error")
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+//go:build !linux
+
+package rtable
+
+// Routes returns the unicast routes in all the kernel routing tables.
+// It is supported only on Linux.
+func Routes() ([]Route, error) {
+	return nil, errNotSupported
+}
@@ -0,0 +1,194 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package rtable implements an importer that builds Application-Layer
+// Traffic Optimization (ALTO) network maps from the kernel routing
+// tables.
+//
+// On Linux, routes are read via netlink, or from /proc/net/route and
+// /proc/net/ipv6_route when netlink is unavailable.
+package rtable
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+var (
+	errNotSupported    = errors.New("not supported on this platform")
+	errInvalidInterval = errors.New("non-positive interval")
+)
+
+// Well-known routing table ids.
+const (
+	TableDefault = 253
+	TableMain    = 254
+	TableLocal   = 255
+)
+
+// A Route represents a unicast route in a kernel routing table.
+type Route struct {
+	Dst       *net.IPNet
+	Gateway   net.IP // nil for directly connected routes
+	Interface string // name of output interface
+	Table     int    // routing table id
+}
+
+// A GroupBy represents a criterion for grouping prefixes into
+// provider-defined identifiers (PIDs).
+type GroupBy int
+
+const (
+	ByInterface GroupBy = iota // PIDs are named "if-" followed by the output interface name
+	ByGateway                  // PIDs are named "gw-" followed by the gateway address, or "connected"
+	ByTable                    // PIDs are named "table-" followed by the routing table name or id
+)
+
+// An Importer represents a builder of network maps from the kernel
+// routing tables.
+type Importer struct {
+	GroupBy GroupBy
+	Tables  []int // routing table ids to import; nil means all tables except the local table
+
+	// Routes returns the routes to import. Nil means Routes.
+	Routes func() ([]Route, error)
+
+	// ErrorLog specifies an optional logger for errors occurred
+	// in Run. Nil means the standard logger.
+	ErrorLog *log.Logger
+}
+
+// Import reads the routing tables and returns a network map. Routes
+// via loopback interfaces and routes to link-local and multicast
+// destinations are ignored. The endpoint address groups of the
+// network map are aggregated, and the version tag is derived from the
+// content.
+func (imp *Importer) Import() (*alto.NetworkMap, error) {
+	routes := imp.Routes
+	if routes == nil {
+		routes = Routes
+	}
+	rts, err := routes()
+	if err != nil {
+		return nil, err
+	}
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	for _, rt := range rts {
+		if !imp.importable(&rt) {
+			continue
+		}
+		typ := "ipv4"
+		if rt.Dst.IP.To4() == nil {
+			typ = "ipv6"
+		}
+		ep, err := alto.ParseEndpoint(typ, rt.Dst.String())
+		if err != nil {
+			return nil, err
+		}
+		pid := imp.pid(&rt)
+		eag, ok := nm.Map[pid]
+		if !ok {
+			eag = make(alto.EndpointAddrGroup)
+			nm.Map[pid] = eag
+		}
+		eag[typ] = append(eag[typ], ep)
+	}
+	nm.Aggregate()
+	vtag, err := alto.ContentVersionTag(nm)
+	if err != nil {
+		return nil, err
+	}
+	nm.VersionTag = vtag
+	return nm, nil
+}
+
+func (imp *Importer) importable(rt *Route) bool {
+	if rt.Dst == nil || rt.Interface == "lo" {
+		return false
+	}
+	if rt.Dst.IP.IsLinkLocalUnicast() || rt.Dst.IP.IsMulticast() || rt.Dst.IP.IsLoopback() {
+		return false
+	}
+	if imp.Tables == nil {
+		return rt.Table != TableLocal
+	}
+	for _, t := range imp.Tables {
+		if t == rt.Table {
+			return true
+		}
+	}
+	return false
+}
+
+var tableNames = map[int]string{TableDefault: "default", TableMain: "main", TableLocal: "local"}
+
+func (imp *Importer) pid(rt *Route) string {
+	switch imp.GroupBy {
+	case ByGateway:
+		if rt.Gateway == nil {
+			return "connected"
+		}
+		return "gw-" + strings.Replace(rt.Gateway.String(), ".", "-", -1)
+	case ByTable:
+		if name, ok := tableNames[rt.Table]; ok {
+			return "table-" + name
+		}
+		return "table-" + strconv.Itoa(rt.Table)
+	default:
+		return "if-" + strings.Replace(rt.Interface, ".", "-", -1)
+	}
+}
+
+// Run imports the routing tables immediately and then every interval
+// until ctx is done, and publishes each network map to s without cost
+// maps, because the cost maps of the latest snapshot depend on the
+// previous network map. Callers recompute cost maps for the published
+// network map and publish them with Store.PublishCostMaps. A network
+// map is published only when its version tag differs from the latest
+// one. Errors are logged and do not stop Run. Run returns the error
+// of ctx, or an error when interval is not positive.
+func (imp *Importer) Run(ctx context.Context, s *alto.Store, interval time.Duration) error {
+	if interval <= 0 {
+		return errInvalidInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := imp.publish(s); err != nil {
+			imp.logf("rtable: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (imp *Importer) publish(s *alto.Store) error {
+	nm, err := imp.Import()
+	if err != nil {
+		return err
+	}
+	if ss := s.Load(); ss != nil && ss.NetworkMap.VersionTag == nm.VersionTag {
+		return nil
+	}
+	_, err = s.Publish(nm, nil)
+	return err
+}
+
+func (imp *Importer) logf(format string, args ...interface{}) {
+	if imp.ErrorLog != nil {
+		imp.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
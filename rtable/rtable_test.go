@@ -0,0 +1,133 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package rtable
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikioh/alto"
+)
+
+func mustRoute(dst, gw, ifname string, table int) Route {
+	_, ipn, _ := net.ParseCIDR(dst)
+	return Route{Dst: ipn, Gateway: net.ParseIP(gw), Interface: ifname, Table: table}
+}
+
+var testRoutes = []Route{
+	mustRoute("0.0.0.0/0", "192.0.2.1", "eth0", TableMain),
+	mustRoute("192.0.2.0/25", "", "eth0", TableMain),
+	mustRoute("192.0.2.128/25", "", "eth0", TableMain),
+	mustRoute("198.51.100.0/24", "192.0.2.2", "eth1.100", TableMain),
+	mustRoute("203.0.113.0/24", "192.0.2.3", "eth1.100", 100),
+	mustRoute("2001:db8::/32", "", "eth0", TableMain),
+	mustRoute("fe80::/64", "", "eth0", TableMain),
+	mustRoute("127.0.0.0/8", "", "lo", TableLocal),
+	mustRoute("192.0.2.10/32", "", "eth0", TableLocal),
+}
+
+func TestImport(t *testing.T) {
+	for _, tt := range []struct {
+		imp  Importer
+		pids map[string]string
+	}{
+		{Importer{}, map[string]string{"if-eth0": "0.0.0.0/0 2001:db8::/32", "if-eth1-100": "198.51.100.0/24 203.0.113.0/24"}},
+		{Importer{GroupBy: ByGateway, Tables: []int{TableMain}}, map[string]string{"gw-192-0-2-1": "0.0.0.0/0", "connected": "192.0.2.0/24 2001:db8::/32", "gw-192-0-2-2": "198.51.100.0/24"}},
+		{Importer{GroupBy: ByTable}, map[string]string{"table-main": "0.0.0.0/0 2001:db8::/32", "table-100": "203.0.113.0/24"}},
+	} {
+		tt.imp.Routes = func() ([]Route, error) { return testRoutes, nil }
+		nm, err := tt.imp.Import()
+		if err != nil {
+			t.Fatalf("Importer.Import failed: %v", err)
+		}
+		if len(nm.Map) != len(tt.pids) {
+			t.Fatalf("got %v; expected %v", nm.Map, tt.pids)
+		}
+		for pid, s := range tt.pids {
+			var ss []string
+			for _, ep := range nm.Endpoints(pid, "") {
+				ss = append(ss, ep.String())
+			}
+			sort.Strings(ss)
+			if strings.Join(ss, " ") != s {
+				t.Fatalf("%s: got %v; expected %v", pid, ss, s)
+			}
+		}
+		if vtag, _ := alto.ContentVersionTag(nm); nm.VersionTag != vtag {
+			t.Fatalf("got %v; expected %v", nm.VersionTag, vtag)
+		}
+	}
+}
+
+func TestImporterRun(t *testing.T) {
+	var mu sync.Mutex
+	rts, calls := testRoutes[:2], 0
+	imp := &Importer{
+		Routes: func() ([]Route, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			return rts, nil
+		},
+		ErrorLog: log.New(ioutil.Discard, "", 0),
+	}
+	s := alto.NewStore()
+	if err := imp.Run(context.Background(), s, 0); err == nil {
+		t.Fatal("Importer.Run succeeded for zero interval")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- imp.Run(ctx, s, time.Millisecond) }()
+
+	wait := func(cond func() bool) {
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal("timed out")
+			}
+		}
+	}
+	wait(func() bool { return s.Load() != nil })
+	vtag := s.Load().NetworkMap.VersionTag
+	mu.Lock()
+	n := calls
+	mu.Unlock()
+	wait(func() bool { mu.Lock(); defer mu.Unlock(); return calls > n+2 })
+	if s.Load().NetworkMap.VersionTag != vtag {
+		t.Fatalf("got %v; expected unchanged %v", s.Load().NetworkMap.VersionTag, vtag)
+	}
+	cm := &alto.CostMap{VersionTag: vtag, Map: make(map[string]alto.DstCosts)}
+	if _, err := s.PublishCostMaps(map[string]*alto.CostMap{"num-routing": cm}); err != nil {
+		t.Fatalf("Store.PublishCostMaps failed: %v", err)
+	}
+	mu.Lock()
+	rts = testRoutes
+	mu.Unlock()
+	wait(func() bool { return s.Load().NetworkMap.VersionTag != vtag })
+	if cm := s.Load().CostMap("num-routing"); cm != nil {
+		t.Fatalf("got cost map depending on %v; expected none", cm.VersionTag)
+	}
+	if pid, ok := s.Load().NetworkMap.Lookup(mustParseEndpoint(t, "198.51.100.1")); !ok || pid != "if-eth1-100" {
+		t.Fatalf("got %v, %v; expected %v", pid, ok, "if-eth1-100")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("got %v; expected %v", err, context.Canceled)
+	}
+}
+
+func mustParseEndpoint(t *testing.T, addr string) alto.Endpoint {
+	ep, err := alto.ParseEndpoint("ipv4", addr)
+	if err != nil {
+		t.Fatalf("alto.ParseEndpoint failed: %v", err)
+	}
+	return ep
+}
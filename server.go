@@ -0,0 +1,131 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Handler represents an HTTP handler that serves an information
+// resource with caching support. It negotiates the media type with
+// the Accept header field, emits ETag, Cache-Control and
+// Last-Modified header fields, and answers conditional requests with
+// http.StatusNotModified.
+type Handler struct {
+	MediaType string        // media type of information resource
+	MaxAge    time.Duration // max-age directive of Cache-Control; zero forces revalidation
+
+	// Resource returns the information resource to serve and
+	// its last modification time. The resource is encoded by
+	// encoding/json on each request unless it is an
+	// EncodedResource. A zero time omits Last-Modified.
+	Resource func() (interface{}, time.Time)
+}
+
+// An EncodedResource represents an information resource encoded in
+// advance. Handler serves its encoding and entity tag as is.
+type EncodedResource struct {
+	Body []byte // JSON encoding
+	ETag string // entity tag
+}
+
+// Encode encodes the information resource v in advance.
+func Encode(v interface{}) (*EncodedResource, error) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return &EncodedResource{Body: b.Bytes(), ETag: ETag(v, b.Bytes())}, nil
+}
+
+// ServeHTTP implements the ServeHTTP method of http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !Negotiate(w, req, h.MediaType, "") {
+		return
+	}
+	v, modTime := h.Resource()
+	er, ok := v.(*EncodedResource)
+	if !ok {
+		var err error
+		if er, err = Encode(v); err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	hdr := w.Header()
+	hdr.Set("ETag", er.ETag)
+	if h.MaxAge > 0 {
+		hdr.Set("Cache-Control", "max-age="+strconv.Itoa(int(h.MaxAge/time.Second)))
+	} else {
+		hdr.Set("Cache-Control", "no-cache")
+	}
+	if !modTime.IsZero() {
+		hdr.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(req, hdr.Get("ETag"), modTime) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	hdr.Set("Content-Type", h.MediaType)
+	hdr.Set("Content-Length", strconv.Itoa(len(er.Body)))
+	if req.Method == "HEAD" {
+		return
+	}
+	w.Write(er.Body)
+}
+
+// ETag returns an entity tag for the information resource v and its
+// JSON encoding b. The entity tag of a network map is derived from its
+// version tag. The entity tag of a cost map is derived from its
+// version tag and content, because the version tag identifies the
+// network map that the cost map depends on. The entity tag of any
+// other resource is derived from its content.
+func ETag(v interface{}, b []byte) string {
+	if r, ok := v.(*Resource); ok {
+		v = r.Data
+	}
+	h := sha256.Sum256(b)
+	sum := hex.EncodeToString(h[:8])
+	switch v := v.(type) {
+	case *NetworkMap:
+		if v.VersionTag != "" {
+			return strconv.Quote(v.VersionTag)
+		}
+	case *CostMap:
+		if v.VersionTag != "" {
+			return strconv.Quote(v.VersionTag + "-" + sum)
+		}
+	}
+	return strconv.Quote(sum)
+}
+
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, s := range strings.Split(inm, ",") {
+			s = strings.TrimSpace(s)
+			if s == "*" || strings.TrimPrefix(s, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modTime.Truncate(time.Second).After(t)
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerCaching(t *testing.T) {
+	r := NewResource("networkmap")
+	decodeTestdata(t, "testdata/resource-networkmap.js", r)
+	modTime := time.Date(2013, 1, 2, 3, 4, 5, 0, time.UTC)
+	h := &Handler{
+		MediaType: MediaTypeNetworkMap,
+		MaxAge:    time.Minute,
+		Resource:  func() (interface{}, time.Time) { return r, modTime },
+	}
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/networkmap", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got %v; expected %v", rw.Code, http.StatusOK)
+	}
+	hdr := rw.Header()
+	if hdr.Get("ETag") != `"1266506139"` {
+		t.Fatalf("got %v; expected %v", hdr.Get("ETag"), `"1266506139"`)
+	}
+	if hdr.Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("got %v; expected %v", hdr.Get("Cache-Control"), "max-age=60")
+	}
+	if hdr.Get("Last-Modified") != "Wed, 02 Jan 2013 03:04:05 GMT" {
+		t.Fatalf("got %v; expected %v", hdr.Get("Last-Modified"), "Wed, 02 Jan 2013 03:04:05 GMT")
+	}
+	if hdr.Get("Content-Type") != MediaTypeNetworkMap {
+		t.Fatalf("got %v; expected %v", hdr.Get("Content-Type"), MediaTypeNetworkMap)
+	}
+
+	for _, tt := range []struct {
+		name, value string
+		code        int
+	}{
+		{"If-None-Match", `"1266506139"`, http.StatusNotModified},
+		{"If-None-Match", `"0", W/"1266506139"`, http.StatusNotModified},
+		{"If-None-Match", `*`, http.StatusNotModified},
+		{"If-None-Match", `"1266506138"`, http.StatusOK},
+		{"If-Modified-Since", "Wed, 02 Jan 2013 03:04:05 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Wed, 02 Jan 2013 03:04:04 GMT", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/networkmap", nil)
+		req.Header.Set(tt.name, tt.value)
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+		if rw.Code != tt.code {
+			t.Fatalf("%v: %v: got %v; expected %v", tt.name, tt.value, rw.Code, tt.code)
+		}
+		if tt.code == http.StatusNotModified && rw.Body.Len() != 0 {
+			t.Fatalf("got %v bytes body for %v", rw.Body.Len(), tt.code)
+		}
+	}
+
+	cm := NewResource("costmap")
+	decodeTestdata(t, "testdata/resource-costmap.js", cm)
+	etag := ETag(cm, []byte("{}"))
+	if etag == ETag(cm, []byte("[]")) {
+		t.Fatalf("got same entity tag %v for different cost maps", etag)
+	}
+}
+
+func TestHandlerEncodedResource(t *testing.T) {
+	r := NewResource("networkmap")
+	decodeTestdata(t, "testdata/resource-networkmap.js", r)
+	er, err := Encode(r)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if er.ETag != `"1266506139"` {
+		t.Fatalf("got %v; expected %v", er.ETag, `"1266506139"`)
+	}
+	h := &Handler{
+		MediaType: MediaTypeNetworkMap,
+		Resource:  func() (interface{}, time.Time) { return er, time.Time{} },
+	}
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest("GET", "/networkmap", nil))
+	if rw.Code != http.StatusOK {
+		t.Fatalf("got %v; expected %v", rw.Code, http.StatusOK)
+	}
+	if rw.Header().Get("ETag") != er.ETag {
+		t.Fatalf("got %v; expected %v", rw.Header().Get("ETag"), er.ETag)
+	}
+	if rw.Body.String() != string(er.Body) {
+		t.Fatalf("got %s; expected %s", rw.Body.Bytes(), er.Body)
+	}
+}
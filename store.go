@@ -0,0 +1,170 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// A Snapshot represents an immutable set of a network map and its
+// dependent cost maps. The version tags of the cost maps are same as
+// the version tag of the network map. Callers must not modify a
+// snapshot.
+type Snapshot struct {
+	NetworkMap *NetworkMap
+	CostMaps   map[string]*CostMap // keyed by cost type name
+}
+
+// CostMap returns the cost map for the cost type name.
+func (ss *Snapshot) CostMap(name string) *CostMap {
+	if ss == nil {
+		return nil
+	}
+	return ss.CostMaps[name]
+}
+
+// A Store represents a concurrency-safe store of snapshots. Readers
+// load the latest snapshot without locking, and writers publish a new
+// snapshot atomically.
+type Store struct {
+	mu sync.Mutex   // serializes writers
+	v  atomic.Value // *Snapshot
+}
+
+// NewStore returns a new empty store.
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Load returns the latest snapshot. It returns nil when nothing has
+// been published yet.
+func (s *Store) Load() *Snapshot {
+	ss, _ := s.v.Load().(*Snapshot)
+	return ss
+}
+
+// Publish publishes a new snapshot consisting of copies of the network
+// map nm and the cost maps cms, which are keyed by cost type name. The
+// version tag of nm must differ from the one of the latest snapshot,
+// and the cost maps must be non-nil and depend on nm, that is, their
+// version tags must be the version tag of nm.
+func (s *Store) Publish(nm *NetworkMap, cms map[string]*CostMap) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.publish(nm.Clone(), cloneCostMaps(cms))
+}
+
+// Update publishes a new snapshot made by fn from copies of the
+// network map and the cost maps of the latest snapshot. When nothing
+// has been published yet, fn receives an empty network map. Cost maps
+// that fn keeps must be updated to depend on the modified network
+// map, or be deleted. Update publishes nothing when fn returns an
+// error.
+func (s *Store) Update(fn func(nm *NetworkMap, cms map[string]*CostMap) error) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nm, cms := &NetworkMap{Map: make(map[string]EndpointAddrGroup)}, make(map[string]*CostMap)
+	if ss := s.Load(); ss != nil {
+		nm, cms = ss.NetworkMap.Clone(), cloneCostMaps(ss.CostMaps)
+	}
+	if err := fn(nm, cms); err != nil {
+		return nil, err
+	}
+	return s.publish(nm, cms)
+}
+
+func (s *Store) publish(nm *NetworkMap, cms map[string]*CostMap) (*Snapshot, error) {
+	if nm == nil {
+		return nil, errNoNetworkMap
+	}
+	if ss := s.Load(); ss != nil && ss.NetworkMap.VersionTag == nm.VersionTag {
+		return nil, errStaleVersionTag
+	}
+	if err := checkDependency(nm, cms); err != nil {
+		return nil, err
+	}
+	nm.index.Store(newPIDIndex(nm.Map))
+	ss := &Snapshot{NetworkMap: nm, CostMaps: cms}
+	s.v.Store(ss)
+	return ss, nil
+}
+
+// PublishCostMaps publishes a new snapshot consisting of the network
+// map of the latest snapshot and copies of the cost maps cms, which
+// are keyed by cost type name. It replaces the cost maps of a network
+// map that has not changed. The cost maps must depend on the network
+// map of the latest snapshot.
+func (s *Store) PublishCostMaps(cms map[string]*CostMap) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	latest := s.Load()
+	if latest == nil {
+		return nil, errNoNetworkMap
+	}
+	cms = cloneCostMaps(cms)
+	if err := checkDependency(latest.NetworkMap, cms); err != nil {
+		return nil, err
+	}
+	ss := &Snapshot{NetworkMap: latest.NetworkMap, CostMaps: cms}
+	s.v.Store(ss)
+	return ss, nil
+}
+
+// checkDependency checks that the cost maps cms depend on the network
+// map nm.
+func checkDependency(nm *NetworkMap, cms map[string]*CostMap) error {
+	for name, cm := range cms {
+		if cm == nil {
+			return fmt.Errorf("cost map %s is nil", name)
+		}
+		if cm.VersionTag != nm.VersionTag {
+			return fmt.Errorf("cost map %s depends on version tag %q instead of %q", name, cm.VersionTag, nm.VersionTag)
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of the network map nm. Endpoints are shared
+// between nm and the copy.
+func (nm *NetworkMap) Clone() *NetworkMap {
+	if nm == nil {
+		return nil
+	}
+	nnm := &NetworkMap{VersionTag: nm.VersionTag, Map: make(map[string]EndpointAddrGroup)}
+	for pid, eag := range nm.Map {
+		neag := make(EndpointAddrGroup)
+		for typ, eps := range eag {
+			neag[typ] = append([]Endpoint(nil), eps...)
+		}
+		nnm.Map[pid] = neag
+	}
+	return nnm
+}
+
+// Clone returns a copy of the cost map cm.
+func (cm *CostMap) Clone() *CostMap {
+	if cm == nil {
+		return nil
+	}
+	ncm := &CostMap{CostType: cm.CostType, VersionTag: cm.VersionTag, Map: make(map[string]DstCosts)}
+	for src, dcs := range cm.Map {
+		ndcs := make(DstCosts)
+		for dst, c := range dcs {
+			ndcs[dst] = c
+		}
+		ncm.Map[src] = ndcs
+	}
+	return ncm
+}
+
+func cloneCostMaps(cms map[string]*CostMap) map[string]*CostMap {
+	ncms := make(map[string]*CostMap)
+	for name, cm := range cms {
+		ncms[name] = cm.Clone()
+	}
+	return ncms
+}
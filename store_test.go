@@ -0,0 +1,102 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	nm := NewResource("networkmap").Data.(*NetworkMap)
+	decodeTestdata(t, "testdata/networkmap.js", nm)
+	cm := NewResource("costmap").Data.(*CostMap)
+	decodeTestdata(t, "testdata/costmap.js", cm)
+
+	s := NewStore()
+	if ss := s.Load(); ss != nil {
+		t.Fatalf("got %v; expected nil", ss)
+	}
+	if _, err := s.Publish(nm, map[string]*CostMap{"num-routing": cm}); err != nil {
+		t.Fatalf("Store.Publish failed: %v", err)
+	}
+	if _, err := s.Publish(nm, map[string]*CostMap{"num-routing": cm}); err == nil {
+		t.Fatalf("Store.Publish succeeded for stale version tag %v", nm.VersionTag)
+	}
+	stale := cm.Clone()
+	stale.VersionTag = "0"
+	nm2 := nm.Clone()
+	nm2.VersionTag = "1"
+	if _, err := s.Publish(nm2, map[string]*CostMap{"num-routing": stale}); err == nil {
+		t.Fatalf("Store.Publish succeeded for cost map depending on version tag %v", stale.VersionTag)
+	}
+	if _, err := s.Publish(nm2, map[string]*CostMap{"num-routing": nil}); err == nil {
+		t.Fatal("Store.Publish succeeded for nil cost map")
+	}
+	if _, err := s.Update(func(nm *NetworkMap, cms map[string]*CostMap) error {
+		nm.VersionTag = "1"
+		cms["num-routing"] = nil
+		return nil
+	}); err == nil {
+		t.Fatal("Store.Update succeeded for nil cost map")
+	}
+	if _, err := s.PublishCostMaps(map[string]*CostMap{"num-routing": stale}); err == nil {
+		t.Fatalf("Store.PublishCostMaps succeeded for cost map depending on version tag %v", stale.VersionTag)
+	}
+	ncm := cm.Clone()
+	ncm.Map["pid1"]["pid1"] = 2
+	if ss, err := s.PublishCostMaps(map[string]*CostMap{"num-routing": ncm}); err != nil {
+		t.Fatalf("Store.PublishCostMaps failed: %v", err)
+	} else if c := ss.CostMap("num-routing").Map["pid1"]["pid1"]; c != 2 {
+		t.Fatalf("got %v; expected %v", c, 2)
+	}
+	nm.Map["pid1"] = nil
+	if ss := s.Load(); ss.NetworkMap.Map["pid1"] == nil {
+		t.Fatalf("snapshot shares network map with publisher")
+	}
+
+	ep := mustParseEndpoint(t, "ipv4", "192.0.2.1")
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				ss := s.Load()
+				if cm := ss.CostMap("num-routing"); cm.VersionTag != ss.NetworkMap.VersionTag {
+					t.Errorf("got %v; expected %v", cm.VersionTag, ss.NetworkMap.VersionTag)
+					return
+				}
+				ss.NetworkMap.Lookup(ep)
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		ep := mustParseEndpoint(t, "ipv4", "10.0."+strconv.Itoa(i)+".0/24")
+		_, err := s.Update(func(nm *NetworkMap, cms map[string]*CostMap) error {
+			nm.Add("pid4", ep)
+			cms["num-routing"].Map["pid4"] = DstCosts{"pid4": 1}
+			cms["num-routing"].VersionTag = nm.VersionTag
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Store.Update failed: %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	ss := s.Load()
+	if eps := ss.NetworkMap.Endpoints("pid4", ""); len(eps) != 100 {
+		t.Fatalf("got %v; expected %v", len(eps), 100)
+	}
+}
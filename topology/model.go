@@ -0,0 +1,218 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mikioh/alto"
+)
+
+// A Model represents a topology that maintains the shortest paths
+// between its nodes and the cost maps computed from them. On a
+// topology change, it recomputes only the shortest paths from the
+// nodes whose paths are affected, and only the cost rows of their
+// PIDs. A Model is not safe for concurrent use.
+type Model struct {
+	g     *graph
+	trees []tree
+	cms   map[string]*alto.CostMap
+}
+
+// An Update represents the result of a topology change.
+type Update struct {
+	CostMaps map[string]*alto.CostMap // new versions of cost maps keyed by cost metric
+
+	// Patches holds the JSON merge patches, as described in RFC
+	// 7396, that turn the previous versions of cost maps into the
+	// new ones. They are keyed by cost metric, and cost maps
+	// that do not change have no patches.
+	Patches map[string][]byte
+}
+
+// NewModel returns a new model of the topology t, which computes the
+// cost maps between the PIDs of the network map nm as Topology.CostMaps
+// does.
+func NewModel(t *Topology, nm *alto.NetworkMap) (*Model, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	g := newGraph(t)
+	if err := g.checkPIDs(nm); err != nil {
+		return nil, err
+	}
+	m := &Model{g: g, trees: g.allShortestPaths()}
+	m.cms = g.costMaps(m.trees, nm.VersionTag)
+	return m, nil
+}
+
+// CostMaps returns copies of the cost maps keyed by cost metric.
+func (m *Model) CostMaps() map[string]*alto.CostMap {
+	cms := make(map[string]*alto.CostMap)
+	for metric, cm := range m.cms {
+		cms[metric] = cm.Clone()
+	}
+	return cms
+}
+
+// link returns the index of the link from node from to node to.
+// Bidirectional links are also found in the reverse direction.
+func (m *Model) link(from, to string) (int, error) {
+	for i, l := range m.g.links {
+		if l.From == from && l.To == to || !l.Directed && l.From == to && l.To == from {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown link %s-%s", from, to)
+}
+
+// SetLinkMetric changes the IGP metric of the link from node from to
+// node to.
+func (m *Model) SetLinkMetric(from, to string, metric float64) (*Update, error) {
+	l, err := m.link(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if metric < 0 {
+		return nil, fmt.Errorf("link %s-%s with negative metric", from, to)
+	}
+	changed := make(map[int]bool)
+	if !m.g.linkDown[l] {
+		for src, tr := range m.trees {
+			for _, uv := range m.g.linkArcs(l) {
+				u, v := uv[0], uv[1]
+				if !tr[u].reached || m.g.nodeDown[v] {
+					continue
+				}
+				p := path{reached: true, metric: tr[u].metric + metric, hops: tr[u].hops + 1, delay: tr[u].delay + m.g.links[l].Delay, pred: u}
+				if tr[v].pred == u || p.less(&tr[v]) {
+					changed[src] = true
+				}
+			}
+		}
+	}
+	m.g.links[l].Metric = metric
+	return m.update(changed, changed)
+}
+
+// LinkDown takes down the link from node from to node to.
+func (m *Model) LinkDown(from, to string) (*Update, error) {
+	l, err := m.link(from, to)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[int]bool)
+	if !m.g.linkDown[l] {
+		for src, tr := range m.trees {
+			for _, uv := range m.g.linkArcs(l) {
+				if tr[uv[1]].reached && tr[uv[1]].pred == uv[0] {
+					changed[src] = true
+				}
+			}
+		}
+	}
+	m.g.linkDown[l] = true
+	return m.update(changed, changed)
+}
+
+// NodeDown takes down the node id and all its links.
+func (m *Model) NodeDown(id string) (*Update, error) {
+	n, ok := m.g.index[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %s", id)
+	}
+	changed, recompute := make(map[int]bool), make(map[int]bool)
+	if !m.g.nodeDown[n] {
+		for src, tr := range m.trees {
+			if !tr[n].reached {
+				continue
+			}
+			changed[src] = true
+			if src == n || tr.transits(n) {
+				recompute[src] = true
+			} else {
+				tr[n] = path{} // n is a leaf of the tree
+			}
+		}
+	}
+	m.g.nodeDown[n] = true
+	return m.update(changed, recompute)
+}
+
+// transits reports whether the paths in the tree t go through the
+// node n.
+func (t tree) transits(n int) bool {
+	for _, p := range t {
+		if p.reached && p.pred == n {
+			return true
+		}
+	}
+	return false
+}
+
+// update recomputes the shortest path trees rooted at the nodes of
+// recompute, and the cost rows of the PIDs of the nodes of changed.
+func (m *Model) update(changed, recompute map[int]bool) (*Update, error) {
+	srcs := make([]int, 0, len(recompute))
+	for src := range recompute {
+		srcs = append(srcs, src)
+	}
+	m.g.updateShortestPaths(m.trees, srcs)
+	pids := make(map[string]bool)
+	for src := range changed {
+		if pid := m.g.nodePID[src]; pid != "" {
+			pids[pid] = true
+		}
+	}
+	olds := make(map[string]map[string]alto.DstCosts)
+	for metric, cm := range m.cms {
+		olds[metric] = make(map[string]alto.DstCosts)
+		for pid := range pids {
+			olds[metric][pid] = cm.Map[pid]
+		}
+	}
+	for pid := range pids {
+		m.g.costRow(m.cms, m.trees, pid)
+	}
+	u := &Update{CostMaps: m.CostMaps(), Patches: make(map[string][]byte)}
+	for metric, cm := range m.cms {
+		patch := make(map[string]interface{})
+		for pid, old := range olds[metric] {
+			if v, ok := diffRow(old, cm.Map[pid]); ok {
+				patch[pid] = v
+			}
+		}
+		if len(patch) == 0 {
+			continue
+		}
+		b, err := json.Marshal(map[string]interface{}{"map": patch})
+		if err != nil {
+			return nil, err
+		}
+		u.Patches[metric] = b
+	}
+	return u, nil
+}
+
+// diffRow returns the JSON merge patch value that turns the cost row
+// old into new. It reports false when the rows are the same.
+func diffRow(old, new alto.DstCosts) (interface{}, bool) {
+	if new == nil {
+		return nil, old != nil
+	}
+	patch := make(map[string]interface{})
+	for dst, c := range new {
+		if oc, ok := old[dst]; !ok || oc != c {
+			patch[dst] = c
+		}
+	}
+	for dst := range old {
+		if _, ok := new[dst]; !ok {
+			patch[dst] = nil
+		}
+	}
+	return patch, len(patch) > 0
+}
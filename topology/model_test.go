@@ -0,0 +1,176 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+// mergePatch applies the JSON merge patch patch to target as
+// described in RFC 7396.
+func mergePatch(target, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]interface{})
+	if !ok {
+		tm = make(map[string]interface{})
+	}
+	for name, v := range pm {
+		if v == nil {
+			delete(tm, name)
+		} else {
+			tm[name] = mergePatch(tm[name], v)
+		}
+	}
+	return tm
+}
+
+func decodeJSON(t *testing.T, v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	return raw
+}
+
+// checkUpdate checks that the update u of the model m holds the same
+// cost maps as the topology topo computed from scratch, and the patches
+// turn the previous cost maps prev into them.
+func checkUpdate(t *testing.T, m *Model, u *Update, topo *Topology, nm *alto.NetworkMap, prev map[string]*alto.CostMap) {
+	cms, err := topo.CostMaps(nm)
+	if err != nil {
+		t.Fatalf("Topology.CostMaps failed: %v", err)
+	}
+	for metric, cm := range cms {
+		if !reflect.DeepEqual(u.CostMaps[metric], cm) {
+			t.Fatalf("%s: got %v; expected %v", metric, u.CostMaps[metric].Map, cm.Map)
+		}
+		if !reflect.DeepEqual(m.CostMaps()[metric], cm) {
+			t.Fatalf("%s: got %v; expected %v", metric, m.CostMaps()[metric].Map, cm.Map)
+		}
+		raw := decodeJSON(t, prev[metric])
+		if b, ok := u.Patches[metric]; ok {
+			var patch interface{}
+			if err := json.Unmarshal(b, &patch); err != nil {
+				t.Fatalf("json.Unmarshal failed: %v", err)
+			}
+			raw = mergePatch(raw, patch)
+		}
+		if expected := decodeJSON(t, cm); !reflect.DeepEqual(raw, expected) {
+			t.Fatalf("%s: got %v; expected %v", metric, raw, expected)
+		}
+	}
+}
+
+func TestModel(t *testing.T) {
+	topo, err := Load(strings.NewReader(testTopology))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	nm := testNetworkMap("pid1", "pid2", "pid3")
+	m, err := NewModel(topo, nm)
+	if err != nil {
+		t.Fatalf("NewModel failed: %v", err)
+	}
+	prev := m.CostMaps()
+	u, err := m.SetLinkMetric("osaka", "nagoya", 5)
+	if err != nil {
+		t.Fatalf("Model.SetLinkMetric failed: %v", err)
+	}
+	if s := `{"map":{"pid1":{"pid2":15},"pid2":{"pid1":15,"pid3":36}}}`; string(u.Patches[RoutingCost]) != s {
+		t.Fatalf("got %s; expected %s", u.Patches[RoutingCost], s)
+	}
+	if _, ok := u.Patches[HopCount]; ok {
+		t.Fatalf("got %s; expected no patch", u.Patches[HopCount])
+	}
+	topo.Links[2].Metric = 5
+	checkUpdate(t, m, u, topo, nm, prev)
+
+	prev = u.CostMaps
+	if u, err = m.NodeDown("sapporo"); err != nil {
+		t.Fatalf("Model.NodeDown failed: %v", err)
+	}
+	if s := `{"map":{"pid1":{"pid3":null},"pid2":{"pid3":null},"pid3":null}}`; string(u.Patches[RoutingCost]) != s {
+		t.Fatalf("got %s; expected %s", u.Patches[RoutingCost], s)
+	}
+
+	if _, err := m.LinkDown("tokyo", "kyoto"); err == nil {
+		t.Fatal("got nil; expected an error for unknown link")
+	}
+	if _, err := m.SetLinkMetric("sapporo", "tokyo", 1); err == nil {
+		t.Fatal("got nil; expected an error for reverse of directed link")
+	}
+}
+
+func TestModelRandomChanges(t *testing.T) {
+	const n = 6
+	topo := testGrid(n)
+	nm := testNetworkMap()
+	for _, n := range topo.Nodes {
+		nm.Map[n.PID] = nil
+	}
+	m, err := NewModel(topo, nm)
+	if err != nil {
+		t.Fatalf("NewModel failed: %v", err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	var downNodes []string
+	for i := 0; i < 64; i++ {
+		prev := m.CostMaps()
+		var u *Update
+		switch l := rnd.Intn(len(topo.Links)); {
+		case i%16 == 15:
+			id := fmt.Sprintf("n%d-%d", rnd.Intn(n), rnd.Intn(n))
+			u, err = m.NodeDown(id)
+			downNodes = append(downNodes, id)
+		case i%8 == 7:
+			u, err = m.LinkDown(topo.Links[l].To, topo.Links[l].From)
+			topo.Links = append(topo.Links[:l], topo.Links[l+1:]...)
+		default:
+			metric := float64(1 + rnd.Intn(10))
+			u, err = m.SetLinkMetric(topo.Links[l].From, topo.Links[l].To, metric)
+			topo.Links[l].Metric = metric
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		checkUpdate(t, m, u, withoutNodes(topo, downNodes), nm, prev)
+	}
+}
+
+// withoutNodes returns a copy of topology t that has no links to the
+// nodes ids, which are detached from their PIDs.
+func withoutNodes(t *Topology, ids []string) *Topology {
+	down := make(map[string]bool)
+	for _, id := range ids {
+		down[id] = true
+	}
+	nt := &Topology{}
+	for _, n := range t.Nodes {
+		if down[n.ID] {
+			n.PID = ""
+		}
+		nt.Nodes = append(nt.Nodes, n)
+	}
+	for _, l := range t.Links {
+		if !down[l.From] && !down[l.To] {
+			nt.Links = append(nt.Links, l)
+		}
+	}
+	return nt
+}
@@ -0,0 +1,245 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package topology
+
+import (
+	"container/heap"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/mikioh/alto"
+)
+
+// An arc represents a direction of link.
+type arc struct {
+	to   int
+	link int // index of link
+}
+
+// A graph represents an indexed form of topology.
+type graph struct {
+	ids      []string         // node ids
+	index    map[string]int   // node indices keyed by id
+	pids     map[string][]int // nodes attached to each PID
+	nodePID  []string         // PID each node is attached to
+	links    []Link
+	arcs     [][]arc // outgoing arcs of each node
+	nodeDown []bool
+	linkDown []bool
+}
+
+func newGraph(t *Topology) *graph {
+	g := &graph{
+		index:    make(map[string]int),
+		pids:     make(map[string][]int),
+		links:    append([]Link(nil), t.Links...),
+		nodePID:  make([]string, len(t.Nodes)),
+		arcs:     make([][]arc, len(t.Nodes)),
+		nodeDown: make([]bool, len(t.Nodes)),
+		linkDown: make([]bool, len(t.Links)),
+	}
+	for i, n := range t.Nodes {
+		g.ids = append(g.ids, n.ID)
+		g.index[n.ID] = i
+		g.nodePID[i] = n.PID
+		if n.PID != "" {
+			g.pids[n.PID] = append(g.pids[n.PID], i)
+		}
+	}
+	for i, l := range t.Links {
+		from, to := g.index[l.From], g.index[l.To]
+		g.arcs[from] = append(g.arcs[from], arc{to: to, link: i})
+		if !l.Directed {
+			g.arcs[to] = append(g.arcs[to], arc{to: from, link: i})
+		}
+	}
+	return g
+}
+
+// linkArcs returns the pairs of nodes at both ends of the arcs of the
+// link l.
+func (g *graph) linkArcs(l int) [][2]int {
+	from, to := g.index[g.links[l].From], g.index[g.links[l].To]
+	if g.links[l].Directed {
+		return [][2]int{{from, to}}
+	}
+	return [][2]int{{from, to}, {to, from}}
+}
+
+// checkPIDs checks that the PIDs of nodes belong to the network map
+// nm.
+func (g *graph) checkPIDs(nm *alto.NetworkMap) error {
+	for pid := range g.pids {
+		if _, ok := nm.Map[pid]; !ok {
+			return fmt.Errorf("pid %s not in network map", pid)
+		}
+	}
+	return nil
+}
+
+// A path represents a shortest path from a source node.
+type path struct {
+	reached bool
+	metric  float64
+	hops    int
+	delay   float64
+	pred    int // previous node; -1 for source node
+}
+
+// less reports whether p is preferred to q. Paths are compared by
+// metric, then by number of hops and delay, so that the choice among
+// equal cost paths does not depend on the order of links.
+func (p *path) less(q *path) bool {
+	switch {
+	case p.reached != q.reached:
+		return p.reached
+	case p.metric != q.metric:
+		return p.metric < q.metric
+	case p.hops != q.hops:
+		return p.hops < q.hops
+	}
+	return p.delay < q.delay
+}
+
+// A tree represents a shortest path tree rooted at a source node. It
+// holds the paths to all the nodes.
+type tree []path
+
+// shortestPaths returns the shortest path tree rooted at src. Nodes
+// and links that are down are excluded.
+func (g *graph) shortestPaths(src int) tree {
+	t := make(tree, len(g.ids))
+	if g.nodeDown[src] {
+		return t
+	}
+	t[src] = path{reached: true, pred: -1}
+	q := &pathQueue{{node: src, path: t[src]}}
+	done := make([]bool, len(g.ids))
+	for q.Len() > 0 {
+		it := heap.Pop(q).(pathItem)
+		if done[it.node] {
+			continue
+		}
+		done[it.node] = true
+		for _, a := range g.arcs[it.node] {
+			if g.linkDown[a.link] || g.nodeDown[a.to] || done[a.to] {
+				continue
+			}
+			l := &g.links[a.link]
+			p := path{reached: true, metric: it.path.metric + l.Metric, hops: it.path.hops + 1, delay: it.path.delay + l.Delay, pred: it.node}
+			if p.less(&t[a.to]) {
+				t[a.to] = p
+				heap.Push(q, pathItem{node: a.to, path: p})
+			}
+		}
+	}
+	return t
+}
+
+type pathItem struct {
+	node int
+	path path
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].path.less(&q[j].path) }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
+
+// allShortestPaths returns the shortest path trees rooted at all the
+// nodes.
+func (g *graph) allShortestPaths() []tree {
+	srcs := make([]int, len(g.ids))
+	for i := range srcs {
+		srcs[i] = i
+	}
+	trees := make([]tree, len(g.ids))
+	g.updateShortestPaths(trees, srcs)
+	return trees
+}
+
+// updateShortestPaths recomputes the shortest path trees of trees
+// rooted at srcs in parallel across CPUs.
+func (g *graph) updateShortestPaths(trees []tree, srcs []int) {
+	n := runtime.GOMAXPROCS(0)
+	if n > len(srcs) {
+		n = len(srcs)
+	}
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for src := range ch {
+				trees[src] = g.shortestPaths(src)
+			}
+		}()
+	}
+	for _, src := range srcs {
+		ch <- src
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// costMaps returns the cost maps computed from the shortest path
+// trees, whose version tags are vtag.
+func (g *graph) costMaps(trees []tree, vtag string) map[string]*alto.CostMap {
+	cms := make(map[string]*alto.CostMap)
+	for _, metric := range []string{RoutingCost, HopCount, Delay} {
+		cms[metric] = &alto.CostMap{
+			CostType:   alto.CostType{CostMetric: metric, CostMode: alto.CostModeNumerical},
+			VersionTag: vtag,
+			Map:        make(map[string]alto.DstCosts),
+		}
+	}
+	for src := range g.pids {
+		g.costRow(cms, trees, src)
+	}
+	return cms
+}
+
+// costRow sets the costs from the PID src in the cost maps cms. The
+// cost between PIDs is the one of the preferred path among the paths
+// between their nodes.
+func (g *graph) costRow(cms map[string]*alto.CostMap, trees []tree, src string) {
+	rows := make(map[string]alto.DstCosts)
+	for metric := range cms {
+		rows[metric] = make(alto.DstCosts)
+	}
+	for dst, dns := range g.pids {
+		var best path
+		for _, sn := range g.pids[src] {
+			for _, dn := range dns {
+				if p := &trees[sn][dn]; p.less(&best) {
+					best = *p
+				}
+			}
+		}
+		if !best.reached {
+			continue
+		}
+		rows[RoutingCost][dst] = best.metric
+		rows[HopCount][dst] = float64(best.hops)
+		rows[Delay][dst] = best.delay
+	}
+	for metric, cm := range cms {
+		if len(rows[metric]) > 0 {
+			cm.Map[src] = rows[metric]
+		} else {
+			delete(cm.Map, src)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+// Package topology implements a network topology model that computes
+// Application-Layer Traffic Optimization (ALTO) cost maps from
+// shortest paths.
+//
+// A topology consists of nodes, which may be attached to
+// provider-defined identifiers (PIDs), and links between them that
+// carry interior gateway protocol (IGP) metrics and delays. Paths
+// between nodes follow the IGP, that is, the paths of the least sum of
+// metrics, and the costs between PIDs are those of the least costly
+// paths between their nodes.
+//
+// A topology is loaded from JSON like the following:
+//
+//	{
+//		"nodes": [
+//			{"id": "tokyo", "pid": "pid1"},
+//			{"id": "osaka", "pid": "pid2"},
+//			{"id": "nagoya"}
+//		],
+//		"links": [
+//			{"from": "tokyo", "to": "nagoya", "metric": 10, "delay": 2500},
+//			{"from": "nagoya", "to": "osaka", "metric": 10, "delay": 1500},
+//			{"from": "tokyo", "to": "osaka", "metric": 30, "delay": 3500, "directed": true}
+//		]
+//	}
+//
+// Links are bidirectional unless directed.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mikioh/alto"
+)
+
+// Cost metrics computed from topologies.
+const (
+	RoutingCost = "routingcost" // sum of IGP metrics
+	HopCount    = "hopcount"    // number of links
+	Delay       = "delay-ow"    // sum of link delays as described in RFC 9439
+)
+
+// A Node represents a node.
+type Node struct {
+	ID  string `json:"id"`
+	PID string `json:"pid,omitempty"` // PID the node is attached to, if any
+}
+
+// A Link represents a link between nodes.
+type Link struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Metric   float64 `json:"metric"`             // IGP metric
+	Delay    float64 `json:"delay"`              // one-way delay in microseconds
+	Directed bool    `json:"directed,omitempty"` // whether the link only goes from From to To
+}
+
+// A Topology represents a network topology.
+type Topology struct {
+	Nodes []Node `json:"nodes"`
+	Links []Link `json:"links"`
+}
+
+// Load reads a topology in JSON from r.
+func Load(r io.Reader) (*Topology, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var t Topology
+	if err := dec.Decode(&t); err != nil {
+		return nil, err
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// LoadFile reads a topology in JSON from the named file.
+func LoadFile(name string) (*Topology, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+func (t *Topology) validate() error {
+	ids := make(map[string]bool)
+	for _, n := range t.Nodes {
+		if n.ID == "" {
+			return fmt.Errorf("node with empty id")
+		}
+		if ids[n.ID] {
+			return fmt.Errorf("duplicate node %s", n.ID)
+		}
+		ids[n.ID] = true
+	}
+	for _, l := range t.Links {
+		if !ids[l.From] || !ids[l.To] {
+			return fmt.Errorf("link %s-%s to unknown node", l.From, l.To)
+		}
+		if l.Metric < 0 || l.Delay < 0 {
+			return fmt.Errorf("link %s-%s with negative metric or delay", l.From, l.To)
+		}
+	}
+	return nil
+}
+
+// CostMaps computes the cost maps of the RoutingCost, HopCount and
+// Delay cost metrics in the numerical cost mode between the PIDs of
+// the network map nm. The cost maps are keyed by cost metric, and
+// depend on nm. PIDs that have no nodes have no costs, nor do pairs of
+// PIDs that have no paths between their nodes. The shortest paths
+// from every node are computed in parallel.
+func (t *Topology) CostMaps(nm *alto.NetworkMap) (map[string]*alto.CostMap, error) {
+	m, err := NewModel(t, nm)
+	if err != nil {
+		return nil, err
+	}
+	return m.cms, nil
+}
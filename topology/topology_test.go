@@ -0,0 +1,137 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package topology
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikioh/alto"
+)
+
+const testTopology = `{
+	"nodes": [
+		{"id": "tokyo", "pid": "pid1"},
+		{"id": "yokohama", "pid": "pid1"},
+		{"id": "osaka", "pid": "pid2"},
+		{"id": "nagoya"},
+		{"id": "sapporo", "pid": "pid3"}
+	],
+	"links": [
+		{"from": "tokyo", "to": "yokohama", "metric": 1, "delay": 0.5},
+		{"from": "yokohama", "to": "nagoya", "metric": 10, "delay": 2.5},
+		{"from": "nagoya", "to": "osaka", "metric": 10, "delay": 1.5},
+		{"from": "tokyo", "to": "osaka", "metric": 30, "delay": 3.5},
+		{"from": "tokyo", "to": "sapporo", "metric": 20, "delay": 8, "directed": true}
+	]
+}`
+
+func testNetworkMap(pids ...string) *alto.NetworkMap {
+	nm := alto.NewResource("networkmap").Data.(*alto.NetworkMap)
+	for i, pid := range pids {
+		ep, _ := alto.ParseEndpoint("ipv4", fmt.Sprintf("192.0.2.%d/32", i))
+		nm.Map[pid] = alto.EndpointAddrGroup{"ipv4": []alto.Endpoint{ep}}
+	}
+	nm.VersionTag = "1266506139"
+	return nm
+}
+
+func TestCostMaps(t *testing.T) {
+	topo, err := Load(strings.NewReader(testTopology))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cms, err := topo.CostMaps(testNetworkMap("pid1", "pid2", "pid3", "pid4"))
+	if err != nil {
+		t.Fatalf("Topology.CostMaps failed: %v", err)
+	}
+	for metric, m := range map[string]map[string]alto.DstCosts{
+		RoutingCost: {
+			"pid1": {"pid1": 0, "pid2": 20, "pid3": 20},
+			"pid2": {"pid1": 20, "pid2": 0, "pid3": 41},
+			"pid3": {"pid3": 0},
+		},
+		HopCount: {
+			"pid1": {"pid1": 0, "pid2": 2, "pid3": 1},
+			"pid2": {"pid1": 2, "pid2": 0, "pid3": 4},
+			"pid3": {"pid3": 0},
+		},
+		Delay: {
+			"pid1": {"pid1": 0, "pid2": 4, "pid3": 8},
+			"pid2": {"pid1": 4, "pid2": 0, "pid3": 12.5},
+			"pid3": {"pid3": 0},
+		},
+	} {
+		cm := cms[metric]
+		if cm.CostType.CostMetric != metric || cm.CostType.CostMode != alto.CostModeNumerical || cm.VersionTag != "1266506139" {
+			t.Fatalf("got %v, %v; expected %v, %v", cm.CostType, cm.VersionTag, metric, "1266506139")
+		}
+		if !reflect.DeepEqual(cm.Map, m) {
+			t.Fatalf("%s: got %v; expected %v", metric, cm.Map, m)
+		}
+	}
+	if _, err := topo.CostMaps(testNetworkMap("pid1", "pid2")); err == nil {
+		t.Fatal("got nil; expected an error for unknown pid")
+	}
+}
+
+var loadErrorTests = []string{
+	`{"nodes": [{"id": "a"}, {"id": "a"}]}`,
+	`{"nodes": [{"id": "a"}], "links": [{"from": "a", "to": "b", "metric": 1}]}`,
+	`{"nodes": [{"id": "a"}, {"id": "b"}], "links": [{"from": "a", "to": "b", "metric": -1}]}`,
+	`{"nodes": [{"id": "a", "name": "a"}]}`,
+}
+
+func TestLoadError(t *testing.T) {
+	for _, s := range loadErrorTests {
+		if _, err := Load(strings.NewReader(s)); err == nil {
+			t.Fatalf("%s: got nil; expected an error", s)
+		}
+	}
+}
+
+// testGrid returns a topology of n by n nodes connected in a grid,
+// each of which is attached to its own PID.
+func testGrid(n int) *Topology {
+	var t Topology
+	id := func(x, y int) string { return fmt.Sprintf("n%d-%d", x, y) }
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			t.Nodes = append(t.Nodes, Node{ID: id(x, y), PID: "pid-" + id(x, y)})
+			if x > 0 {
+				t.Links = append(t.Links, Link{From: id(x-1, y), To: id(x, y), Metric: float64(1 + (x*7+y*3)%5), Delay: 1})
+			}
+			if y > 0 {
+				t.Links = append(t.Links, Link{From: id(x, y-1), To: id(x, y), Metric: float64(1 + (x*3+y*7)%5), Delay: 1})
+			}
+		}
+	}
+	return &t
+}
+
+func TestAllShortestPaths(t *testing.T) {
+	g := newGraph(testGrid(8))
+	trees := g.allShortestPaths()
+	for src := range g.ids {
+		if tr := g.shortestPaths(src); !reflect.DeepEqual(trees[src], tr) {
+			t.Fatalf("%s: got %v; expected %v", g.ids[src], trees[src], tr)
+		}
+	}
+}
+
+func BenchmarkCostMaps(b *testing.B) {
+	topo := testGrid(32)
+	nm := testNetworkMap()
+	for _, n := range topo.Nodes {
+		nm.Map[n.PID] = nil
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := topo.CostMaps(nm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CanonicalJSON returns the canonical JSON encoding of the network
+// map nm. PIDs and address types are sorted, and IP address prefixes
+// are aggregated across PIDs as the Aggregate method does, so that
+// maps encoded alike always resolve addresses to the same PIDs.
+// Endpoints of each address type are sorted.
+func (nm *NetworkMap) CanonicalJSON() ([]byte, error) {
+	return json.Marshal(nm.canonical(true))
+}
+
+func (nm *NetworkMap) canonical(vtag bool) map[string]interface{} {
+	raw := make(map[string]interface{})
+	if vtag {
+		raw["map-vtag"] = nm.VersionTag
+	}
+	cnm := nm.Clone()
+	cnm.Aggregate()
+	nmd := make(map[string]interface{})
+	for pid, eag := range cnm.Map {
+		neag := make(EndpointAddrGroup)
+		for typ, eps := range eag {
+			if len(eps) > 0 {
+				neag[typ] = sortEndpoints(eps)
+			}
+		}
+		nmd[pid] = neag.encode()
+	}
+	raw["map"] = nmd
+	return raw
+}
+
+// CanonicalJSON returns the canonical JSON encoding of the cost map
+// cm. PIDs are sorted and costs are encoded in the shortest decimal
+// notation without exponent. The cost type description is omitted.
+func (cm *CostMap) CanonicalJSON() ([]byte, error) {
+	raw, err := cm.canonical(true)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+func (cm *CostMap) canonical(vtag bool) (map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	raw["cost-type"] = CostType{CostMetric: cm.CostType.CostMetric, CostMode: cm.CostType.CostMode, CostSource: cm.CostType.CostSource}
+	if vtag {
+		raw["map-vtag"] = cm.VersionTag
+	}
+	cmd := make(map[string]map[string]json.Number)
+	for src, dcs := range cm.Map {
+		ndcs := make(map[string]json.Number)
+		for dst, c := range dcs {
+			if math.IsNaN(c) || math.IsInf(c, 0) {
+				return nil, fmt.Errorf("invalid cost %v from %s to %s", c, src, dst)
+			}
+			if c == 0 {
+				c = 0 // normalizes negative zero
+			}
+			ndcs[dst] = json.Number(strconv.FormatFloat(c, 'f', -1, 64))
+		}
+		cmd[src] = ndcs
+	}
+	raw["map"] = cmd
+	return raw, nil
+}
+
+// newVersionTag returns a random version tag.
+func newVersionTag() string {
+	var b [16]byte
+	rand.Read(b[:]) // never fails
+	return hex.EncodeToString(b[:])
+}
+
+// ContentVersionTag returns a version tag derived from the content of
+// the information resource data d, which must be a network map or a
+// cost map. The version tag is the hexadecimal SHA-256 hash of the
+// canonical JSON encoding of d excluding its version tag, so the
+// same content always produces the same version tag.
+func ContentVersionTag(d Data) (string, error) {
+	var raw map[string]interface{}
+	switch d := d.(type) {
+	case *NetworkMap:
+		raw = d.canonical(false)
+	case *CostMap:
+		var err error
+		if raw, err = d.canonical(false); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported resource type %T", d)
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2013 Mikio Hara. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE.
+
+package alto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNetworkMapContentVersionTag(t *testing.T) {
+	nm1 := NewResource("networkmap").Data.(*NetworkMap)
+	if err := json.Unmarshal([]byte(`{"map-vtag": "1", "map": {"pid1": {"ipv4": ["192.0.2.0/24", "198.51.100.0/25"]}, "pid2": {"ipv6": ["::/0"]}}}`), nm1); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	nm2 := NewResource("networkmap").Data.(*NetworkMap)
+	if err := json.Unmarshal([]byte(`{"map-vtag": "2", "map": {"pid2": {"ipv6": ["::/0"]}, "pid1": {"ipv4": ["198.51.100.0/25", "192.0.2.128/25", "192.0.2.0/25", "192.0.2.1"]}}}`), nm2); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	vtag1, err := ContentVersionTag(nm1)
+	if err != nil {
+		t.Fatalf("ContentVersionTag failed: %v", err)
+	}
+	vtag2, err := ContentVersionTag(nm2)
+	if err != nil {
+		t.Fatalf("ContentVersionTag failed: %v", err)
+	}
+	if vtag1 != vtag2 {
+		t.Fatalf("got %v and %v; expected same version tags", vtag1, vtag2)
+	}
+	b1, _ := nm1.CanonicalJSON()
+	nm2.VersionTag = nm1.VersionTag
+	b2, _ := nm2.CanonicalJSON()
+	if string(b1) != string(b2) {
+		t.Fatalf("got %s and %s; expected same encodings", b1, b2)
+	}
+	nm2.Add("pid2", mustParseEndpoint(t, "ipv4", "203.0.113.0/24"))
+	if vtag2, _ = ContentVersionTag(nm2); vtag1 == vtag2 {
+		t.Fatalf("got same version tag %v for different maps", vtag1)
+	}
+}
+
+func TestNetworkMapContentVersionTagLongestPrefixMatch(t *testing.T) {
+	nm1 := NewResource("networkmap").Data.(*NetworkMap)
+	if err := json.Unmarshal([]byte(`{"map": {"pidA": {"ipv4": ["10.0.0.0/8", "10.1.1.0/24"]}, "pidB": {"ipv4": ["10.1.0.0/16"]}}}`), nm1); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	nm2 := NewResource("networkmap").Data.(*NetworkMap)
+	if err := json.Unmarshal([]byte(`{"map": {"pidA": {"ipv4": ["10.0.0.0/8"]}, "pidB": {"ipv4": ["10.1.0.0/16"]}}}`), nm2); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	vtag1, _ := ContentVersionTag(nm1)
+	vtag2, _ := ContentVersionTag(nm2)
+	if vtag1 == vtag2 {
+		t.Fatalf("got same version tag %v for maps resolving 10.1.1.5 to different pids", vtag1)
+	}
+	if len(nm1.Map["pidA"]["ipv4"]) != 2 {
+		t.Fatalf("ContentVersionTag modified the map: %v", nm1.Map)
+	}
+}
+
+func TestCostMapContentVersionTag(t *testing.T) {
+	cm1 := NewResource("costmap").Data.(*CostMap)
+	decodeTestdata(t, "testdata/costmap.js", cm1)
+	cm2 := cm1.Clone()
+	cm2.VersionTag = "0"
+	cm2.CostType.Description = ""
+	cm2.Map["pid1"]["pid1"] = 1.0
+	vtag1, err := ContentVersionTag(cm1)
+	if err != nil {
+		t.Fatalf("ContentVersionTag failed: %v", err)
+	}
+	vtag2, err := ContentVersionTag(cm2)
+	if err != nil {
+		t.Fatalf("ContentVersionTag failed: %v", err)
+	}
+	if vtag1 != vtag2 || len(vtag1) != 64 {
+		t.Fatalf("got %v and %v; expected same version tags", vtag1, vtag2)
+	}
+	cm2.Map["pid1"]["pid1"] = 1.5
+	if vtag2, _ = ContentVersionTag(cm2); vtag1 == vtag2 {
+		t.Fatalf("got same version tag %v for different maps", vtag1)
+	}
+	cm2.Map["pid1"]["pid1"] = 1.0
+	cm2.CostType.CostSource = CostSourceEstimation
+	if vtag2, _ = ContentVersionTag(cm2); vtag1 == vtag2 {
+		t.Fatalf("got same version tag %v for different cost sources", vtag1)
+	}
+	b, err := cm1.CanonicalJSON()
+	if err != nil {
+		t.Fatalf("CostMap.CanonicalJSON failed: %v", err)
+	}
+	const canonical = `{"cost-type":{"cost-metric":"routingcost","cost-mode":"numerical"},"map":{"pid1":{"pid1":1,"pid2":5,"pid3":10},"pid2":{"pid1":5,"pid2":1,"pid3":15},"pid3":{"pid1":20,"pid2":15}},"map-vtag":"1266506139"}`
+	if string(b) != canonical {
+		t.Fatalf("got %s; expected %s", b, canonical)
+	}
+}